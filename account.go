@@ -0,0 +1,218 @@
+package steamgo
+
+import (
+	"bytes"
+	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
+	"time"
+)
+
+// Keeps track of the state of the logged on account as Steam reports it.
+type Account struct {
+	mutex sync.RWMutex
+
+	wallet          WalletInfo
+	sessionToken    uint64
+	email           EmailInfo
+	limited         LimitedAccountInfo
+	vacBans         []uint32
+	vanityUrl       string
+	webApiUserNonce string
+
+	client *Client
+}
+
+type WalletInfo struct {
+	HasWallet bool
+	// In the smallest unit of the currency, e.g. cents
+	Balance  int32
+	Currency ECurrencyCode
+}
+
+type EmailInfo struct {
+	Address     string
+	IsValidated bool
+	// Whether changing the password or secret question requires a code sent to the address
+	CredentialChangeRequiresCode bool
+}
+
+type LimitedAccountInfo struct {
+	IsLimited                      bool
+	IsCommunityBanned              bool
+	IsLocked                       bool
+	IsLimitedAllowedToInviteFriend bool
+}
+
+// Gets the wallet balance and currency
+func (a *Account) GetWallet() WalletInfo {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.wallet
+}
+
+// Gets the session token Steam sent after logging on
+func (a *Account) GetSessionToken() uint64 {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.sessionToken
+}
+
+// Gets the account's email address and whether it is validated
+func (a *Account) GetEmailInfo() EmailInfo {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.email
+}
+
+// Gets whether the account is limited, locked or community banned
+func (a *Account) GetLimitedInfo() LimitedAccountInfo {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.limited
+}
+
+// Gets the ids of the apps the account is VAC banned in
+func (a *Account) GetVACBans() []uint32 {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return append([]uint32(nil), a.vacBans...)
+}
+
+// Gets the custom part of the account's profile URL
+func (a *Account) GetVanityUrl() string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.vanityUrl
+}
+
+// Gets the latest nonce for authenticating with the Web API
+func (a *Account) GetWebApiUserNonce() string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.webApiUserNonce
+}
+
+func (a *Account) registerHandlers(r *Router) {
+	r.HandleBody(EMsg_ClientLogOnResponse, a.handleLogOnResponse)
+	r.HandleBody(EMsg_ClientWalletInfoUpdate, a.handleWalletInfo)
+	r.HandleBody(EMsg_ClientSessionToken, a.handleSessionToken)
+	r.HandleBody(EMsg_ClientEmailAddrInfo, a.handleEmailAddrInfo)
+	r.HandleBody(EMsg_ClientIsLimitedAccount, a.handleIsLimitedAccount)
+	r.Handle(EMsg_ClientVACBanStatus, a.handleVACBanStatus)
+	r.HandleBody(EMsg_ClientVanityURLChangedNotification, a.handleVanityUrlChanged)
+	r.HandleBody(EMsg_ClientRequestWebAPIAuthenticateUserNonceResponse, a.handleWebAPIUserNonce)
+	r.Handle(EMsg_ClientMarketingMessageUpdate2, a.handleMarketingMessageUpdate)
+}
+
+func (a *Account) handleLogOnResponse(packet *PacketMsg, body *CMsgClientLogonResponse) {
+	if EResult(body.GetEresult()) != EResult_OK {
+		return
+	}
+	a.mutex.Lock()
+	a.vanityUrl = body.GetVanityUrl()
+	a.webApiUserNonce = body.GetWebapiAuthenticateUserNonce()
+	a.mutex.Unlock()
+}
+
+func (a *Account) handleWalletInfo(packet *PacketMsg, body *CMsgClientWalletInfoUpdate) {
+	wallet := WalletInfo{
+		HasWallet: body.GetHasWallet(),
+		Balance:   body.GetBalance(),
+		Currency:  ECurrencyCode(body.GetCurrency()),
+	}
+	a.mutex.Lock()
+	a.wallet = wallet
+	a.mutex.Unlock()
+	a.client.Emit(WalletInfoEvent{wallet})
+}
+
+func (a *Account) handleSessionToken(packet *PacketMsg, body *CMsgClientSessionToken) {
+	a.mutex.Lock()
+	a.sessionToken = body.GetToken()
+	a.mutex.Unlock()
+	a.client.Emit(SessionTokenEvent{body.GetToken()})
+}
+
+func (a *Account) handleEmailAddrInfo(packet *PacketMsg, body *CMsgClientEmailAddrInfo) {
+	email := EmailInfo{
+		Address:                      body.GetEmailAddress(),
+		IsValidated:                  body.GetEmailIsValidated(),
+		CredentialChangeRequiresCode: body.GetPasswordOrSecretqaChangeRequiresCode(),
+	}
+	a.mutex.Lock()
+	a.email = email
+	a.mutex.Unlock()
+	a.client.Emit(EmailInfoEvent{email})
+}
+
+func (a *Account) handleIsLimitedAccount(packet *PacketMsg, body *CMsgClientIsLimitedAccount) {
+	limited := LimitedAccountInfo{
+		IsLimited:                      body.GetBisLimitedAccount(),
+		IsCommunityBanned:              body.GetBisCommunityBanned(),
+		IsLocked:                       body.GetBisLockedAccount(),
+		IsLimitedAllowedToInviteFriend: body.GetBisLimitedAccountAllowedToInviteFriends(),
+	}
+	a.mutex.Lock()
+	a.limited = limited
+	a.mutex.Unlock()
+	a.client.Emit(LimitedAccountEvent{limited})
+}
+
+func (a *Account) handleVACBanStatus(packet *PacketMsg) {
+	body := new(MsgClientVACBanStatus)
+	msg := packet.ReadClientMsg(body)
+	r := bytes.NewReader(msg.Payload)
+	var bans []uint32
+	for i := uint32(0); i < body.NumBans; i++ {
+		appId, err := ReadUint32(r)
+		if err != nil {
+			break
+		}
+		bans = append(bans, appId)
+	}
+	a.mutex.Lock()
+	a.vacBans = bans
+	a.mutex.Unlock()
+	a.client.Emit(VACStatusEvent{append([]uint32(nil), bans...)})
+}
+
+func (a *Account) handleVanityUrlChanged(packet *PacketMsg, body *CMsgClientVanityURLChangedNotification) {
+	a.mutex.Lock()
+	a.vanityUrl = body.GetVanityUrl()
+	a.mutex.Unlock()
+	a.client.Emit(VanityUrlChangedEvent{body.GetVanityUrl()})
+}
+
+func (a *Account) handleWebAPIUserNonce(packet *PacketMsg, body *CMsgClientRequestWebAPIAuthenticateUserNonceResponse) {
+	if EResult(body.GetEresult()) != EResult_OK {
+		return
+	}
+	a.mutex.Lock()
+	a.webApiUserNonce = body.GetWebapiAuthenticateUserNonce()
+	a.mutex.Unlock()
+}
+
+func (a *Account) handleMarketingMessageUpdate(packet *PacketMsg) {
+	body := new(MsgClientMarketingMessageUpdate2)
+	msg := packet.ReadClientMsg(body)
+	r := bytes.NewReader(msg.Payload)
+	event := MarketingMessageEvent{
+		UpdateTime: time.Unix(int64(body.MarketingMessageUpdateTime), 0),
+	}
+	for i := uint32(0); i < body.Count; i++ {
+		length, err := ReadInt32(r)
+		if err != nil || length < 4 || int(length-4) > r.Len() {
+			break
+		}
+		data, err := ReadBytes(r, length-4) // the length includes itself
+		if err != nil {
+			break
+		}
+		dr := bytes.NewReader(data)
+		id, _ := ReadUint64(dr)
+		url, _ := ReadString(dr)
+		flags, _ := ReadUint32(dr)
+		event.Messages = append(event.Messages, MarketingMessage{id, url, flags})
+	}
+	a.client.Emit(event)
+}
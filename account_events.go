@@ -0,0 +1,41 @@
+package steamgo
+
+import (
+	"time"
+)
+
+type WalletInfoEvent struct {
+	Wallet WalletInfo
+}
+
+type SessionTokenEvent struct {
+	Token uint64
+}
+
+type EmailInfoEvent struct {
+	Email EmailInfo
+}
+
+type LimitedAccountEvent struct {
+	Info LimitedAccountInfo
+}
+
+// Emitted when Steam reports the apps the account is VAC banned in.
+type VACStatusEvent struct {
+	BannedApps []uint32
+}
+
+type VanityUrlChangedEvent struct {
+	VanityUrl string
+}
+
+type MarketingMessage struct {
+	Id    uint64 `json:",string"`
+	Url   string
+	Flags uint32
+}
+
+type MarketingMessageEvent struct {
+	UpdateTime time.Time
+	Messages   []MarketingMessage
+}
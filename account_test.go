@@ -0,0 +1,88 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"encoding/binary"
+	. "github.com/gamingrobot/steamgo/internal"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Subscribes to events of the handler's type and returns a channel receiving them.
+func subscribeChan(client *Client, handler interface{}) <-chan interface{} {
+	events := make(chan interface{}, 10)
+	eventType := reflect.TypeOf(handler).In(0)
+	client.subscribe(eventType, func(event interface{}) {
+		events <- event
+	}, DefaultSubscriptionOptions)
+	return events
+}
+
+func receiveEvent(t *testing.T, events <-chan interface{}) interface{} {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the event")
+		return nil
+	}
+}
+
+func TestAccountEventsAreValues(t *testing.T) {
+	vacPayload := new(bytes.Buffer)
+	binary.Write(vacPayload, binary.LittleEndian, uint32(440))
+	vac := NewMsgClientVACBanStatus()
+	vac.NumBans = 1
+
+	tests := []struct {
+		handler interface{}
+		msg     IMsg
+		check   func(event interface{}) bool
+	}{{
+		func(WalletInfoEvent) {},
+		NewClientMsgProtobuf(EMsg_ClientWalletInfoUpdate, &CMsgClientWalletInfoUpdate{
+			HasWallet: proto.Bool(true),
+			Balance:   proto.Int32(1000),
+		}),
+		func(event interface{}) bool { return event.(WalletInfoEvent).Wallet.Balance == 1000 },
+	}, {
+		func(SessionTokenEvent) {},
+		NewClientMsgProtobuf(EMsg_ClientSessionToken, &CMsgClientSessionToken{Token: proto.Uint64(42)}),
+		func(event interface{}) bool { return event.(SessionTokenEvent).Token == 42 },
+	}, {
+		func(EmailInfoEvent) {},
+		NewClientMsgProtobuf(EMsg_ClientEmailAddrInfo, &CMsgClientEmailAddrInfo{EmailAddress: proto.String("a@example.com")}),
+		func(event interface{}) bool { return event.(EmailInfoEvent).Email.Address == "a@example.com" },
+	}, {
+		func(LimitedAccountEvent) {},
+		NewClientMsgProtobuf(EMsg_ClientIsLimitedAccount, &CMsgClientIsLimitedAccount{BisLimitedAccount: proto.Bool(true)}),
+		func(event interface{}) bool { return event.(LimitedAccountEvent).Info.IsLimited },
+	}, {
+		func(VACStatusEvent) {},
+		NewClientMsg(vac, vacPayload.Bytes()),
+		func(event interface{}) bool {
+			return reflect.DeepEqual(event.(VACStatusEvent).BannedApps, []uint32{440})
+		},
+	}, {
+		func(VanityUrlChangedEvent) {},
+		NewClientMsgProtobuf(EMsg_ClientVanityURLChangedNotification, &CMsgClientVanityURLChangedNotification{
+			VanityUrl: proto.String("gaben"),
+		}),
+		func(event interface{}) bool { return event.(VanityUrlChangedEvent).VanityUrl == "gaben" },
+	}, {
+		func(MarketingMessageEvent) {},
+		NewClientMsg(NewMsgClientMarketingMessageUpdate2(), nil),
+		func(event interface{}) bool { return len(event.(MarketingMessageEvent).Messages) == 0 },
+	}}
+
+	for _, test := range tests {
+		client := NewClient()
+		events := subscribeChan(client, test.handler)
+		client.handlePacket(packetOf(t, test.msg))
+		if event := receiveEvent(t, events); !test.check(event) {
+			t.Fatalf("Unexpected event %#v", event)
+		}
+	}
+}
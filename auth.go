@@ -2,25 +2,65 @@ package steamgo
 
 import (
 	"code.google.com/p/goprotobuf/proto"
-	"crypto/sha1"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gamingrobot/steamgo/connection"
 	. "github.com/gamingrobot/steamgo/internal"
 	. "github.com/gamingrobot/steamgo/steamid"
+	"github.com/gamingrobot/steamgo/vault"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type Auth struct {
-	client  *Client
-	details *LogOnDetails
+	// Saves the sentry file Steam sends after logging on with a Steam Guard code.
+	// If set, LogOn loads it to fill in SentryFileHash. Without it or an AccountStore,
+	// only sentry files Steam sends in one part can be accepted.
+	SentryStore SentryStore
+	// Consulted when Steam asks for a Steam Guard code. The logon is then retried
+	// automatically with the code. If nil, a FatalError wrapping a *SteamGuardError
+	// is emitted instead.
+	CredentialProvider CredentialProvider
+	// Saves the sentry file, login keys and refresh tokens of the account and the
+	// cookies of Web. If set, LogOn only needs the username once they are stored,
+	// and the sentry file is kept here unless a SentryStore is set.
+	AccountStore vault.AccountStore
+
+	client *Client
+
+	mutex         sync.RWMutex // guarding details, gameServer, anonymous, guardAttempts, logOnConn and loggedOff
+	details       *LogOnDetails
+	gameServer    *GameServerLogOnDetails // if we logged on as a game server
+	anonymous     bool                    // if we logged on as an anonymous user
+	guardAttempts int                     // codes tried since the last successful logon
+	logOnConn     connection.Connection   // waiting for the logon response on this connection
+	loggedOff     chan struct{}           // closed when a pending LogOff is done
 }
 
 type LogOnDetails struct {
-	Username       string
-	Password       string
-	AuthCode       string
+	Username string
+	// May be empty if a LoginKey or RefreshToken is given
+	Password string
+	// Logs on without a password. Keys are received in a LoginKeyEvent after
+	// logging on with ShouldRememberPassword.
+	LoginKey string
+	// Requests a login key for the next logon.
+	ShouldRememberPassword bool
+	// Logs on with the refresh token of an authentication session instead of a
+	// password, see Authentication. Not AuthTokens.AccessToken: despite its name,
+	// the access_token field of the logon message takes the refresh token.
+	RefreshToken string
+	// The code Steam sent by email
+	AuthCode string
+	// The code of the mobile authenticator
+	TwoFactorCode  string
 	SentryFileHash []byte
 }
 
+var ErrNoCredentials = errors.New("Username and password, login key or refresh token must be set")
+
 // Log on with the given details. You must always specify username and
 // password. For the first login, don't set an authcode or a hash and you'll receive an error
 // and Steam will send you an authcode. Then you have to login again, this time with the authcode.
@@ -28,48 +68,235 @@ type LogOnDetails struct {
 // you to login without using an authcode in the future.
 //
 // If you don't use Steam Guard, username and password are enough.
-//TODO: Make sure Steam Guard works
-func (a *Auth) LogOn(details LogOnDetails) {
-	if len(details.Username) == 0 || len(details.Password) == 0 {
-		panic("Username and password must be set!")
+//
+// To avoid storing the password, log on with ShouldRememberPassword once and
+// save the key of the following LoginKeyEvent. Later logons only need the username
+// and that key. If Steam rejects it, a LoginKeyRejectedEvent is emitted and the
+// client disconnects. While remembering the password, the client keeps the latest
+// key for logging on again after reconnecting.
+//
+// Accounts can also log on with the refresh token of an Authentication session,
+// which replaces the password and Steam Guard codes.
+//
+// Instead of handling codes yourself, you can set a CredentialProvider which is asked
+// for them when needed, e.g. a guard.Authenticator for accounts with a mobile
+// authenticator. Codes it returned are reused if LogOn is called again without
+// a code before the logon succeeded, e.g. on the ConnectedEvent after the retry
+// reconnected. While waiting for a logon response, further calls are ignored.
+//
+// With an AccountStore, the password is only needed until Steam issued a login key
+// or an Authentication session saved a refresh token.
+//
+// Returns ErrNoCredentials without logging on if the username is missing, or the
+// password, login key and refresh token are.
+func (a *Auth) LogOn(details LogOnDetails) error {
+	a.loadCredentials(&details)
+	if len(details.Username) == 0 ||
+		(len(details.Password) == 0 && len(details.LoginKey) == 0 && len(details.RefreshToken) == 0) {
+		if len(details.Username) != 0 && a.AccountStore != nil {
+			a.client.Fatalf("No password or stored credentials for %v", details.Username)
+			return nil
+		}
+		return ErrNoCredentials
+	}
+	if a.AccountStore != nil {
+		// so that Steam issues a login key we can store
+		details.ShouldRememberPassword = true
+	}
+
+	a.mutex.Lock()
+	if !a.beginLogOn() {
+		a.mutex.Unlock()
+		return nil
 	}
+	a.gameServer = nil
+	a.anonymous = false
+	if a.details != nil && a.details.Username == details.Username &&
+		details.AuthCode == "" && details.TwoFactorCode == "" {
+		details.AuthCode = a.details.AuthCode
+		details.TwoFactorCode = a.details.TwoFactorCode
+	}
+	a.details = &details
+	a.mutex.Unlock()
 
 	logon := new(CMsgClientLogon)
 	logon.AccountName = &details.Username
-	logon.Password = &details.Password
+	if details.RefreshToken != "" {
+		logon.AccessToken = proto.String(details.RefreshToken)
+		if details.ShouldRememberPassword {
+			logon.ShouldRememberPassword = proto.Bool(true)
+		}
+	} else if details.LoginKey != "" {
+		logon.LoginKey = proto.String(details.LoginKey)
+		// Steam only accepts login keys if we keep remembering the password
+		logon.ShouldRememberPassword = proto.Bool(true)
+	} else {
+		logon.Password = &details.Password
+		if details.ShouldRememberPassword {
+			logon.ShouldRememberPassword = proto.Bool(true)
+		}
+	}
 	if details.AuthCode != "" {
 		logon.AuthCode = proto.String(details.AuthCode)
 	}
+	if details.TwoFactorCode != "" {
+		logon.TwoFactorCode = proto.String(details.TwoFactorCode)
+	}
 	logon.ClientLanguage = proto.String("english")
 	logon.ProtocolVersion = proto.Uint32(MsgClientLogon_CurrentProtocol)
 	logon.ShaSentryfile = details.SentryFileHash
+	if store := a.sentryStore(); logon.ShaSentryfile == nil && store != nil {
+		sentry, err := store.Load(details.Username)
+		if err != nil {
+			a.client.Errorf("Error loading the sentry file: %v", err)
+		}
+		logon.ShaSentryfile = sentryHash(sentry)
+	}
+	if logon.ShaSentryfile != nil {
+		logon.EresultSentryfile = proto.Int32(int32(EResult_OK))
+	} else {
+		logon.EresultSentryfile = proto.Int32(int32(EResult_FileNotFound))
+	}
 
 	atomic.StoreUint64(&a.client.steamId, uint64(NewIdAdv(0, 1, int32(EUniverse_Public), EAccountType_Individual)))
 
 	a.client.Write(NewClientMsgProtobuf(EMsg_ClientLogon, logon))
+	return nil
+}
+
+// Logs on again with the last details after reconnecting.
+// Returns false if LogOn was never called.
+func (a *Auth) relogOn() bool {
+	a.mutex.RLock()
+	details, gameServer, anonymous := a.details, a.gameServer, a.anonymous
+	a.mutex.RUnlock()
+	switch {
+	case anonymous:
+		a.LogOnAnonymous()
+	case gameServer != nil:
+		a.logOnGameServer(*gameServer)
+	case details != nil:
+		a.LogOn(*details)
+	default:
+		return false
+	}
+	return true
+}
+
+// Returns false if we are already waiting for a logon response on the current
+// connection. The mutex must be held.
+func (a *Auth) beginLogOn() bool {
+	conn := a.client.currentConnection()
+	if conn != nil && a.logOnConn == conn {
+		return false
+	}
+	a.logOnConn = conn
+	return true
+}
+
+// Logs on to an anonymous user account. It can't use the community features,
+// but can query public information like app info and content without an account.
+func (a *Auth) LogOnAnonymous() {
+	a.mutex.Lock()
+	if !a.beginLogOn() {
+		a.mutex.Unlock()
+		return
+	}
+	a.details = nil
+	a.gameServer = nil
+	a.anonymous = true
+	a.mutex.Unlock()
+
+	logon := new(CMsgClientLogon)
+	logon.ClientLanguage = proto.String("english")
+	logon.ProtocolVersion = proto.Uint32(MsgClientLogon_CurrentProtocol)
+
+	atomic.StoreUint64(&a.client.steamId, uint64(NewIdAdv(0, 0, int32(EUniverse_Public), EAccountType_AnonUser)))
+
+	a.client.Write(NewClientMsgProtobuf(EMsg_ClientLogon, logon))
+}
+
+// Logs on as an anonymous game server for the given app. Anonymous game servers
+// don't need a login token, but don't appear in the server browser either.
+func (a *Auth) LogOnAnonymousGameServer(appId uint32) {
+	a.logOnGameServer(GameServerLogOnDetails{AppId: appId})
 }
 
-func (a *Auth) HandlePacket(packet *PacketMsg) {
-	switch packet.EMsg {
-	case EMsg_ClientLogOnResponse:
-		a.handleLogOnResponse(packet)
-	case EMsg_ClientNewLoginKey:
-		a.handleLoginKey(packet)
-	case EMsg_ClientSessionToken:
-		a.handleSessionToken(packet)
-	case EMsg_ClientLoggedOff:
-		a.handleLoggedOff(packet)
-	case EMsg_ClientUpdateMachineAuth:
-		a.handleUpdateMachineAuth(packet)
-	case EMsg_ClientAccountInfo:
-		a.handleAccountInfo(packet)
-	case EMsg_ClientWalletInfoUpdate:
-		a.handleWalletInfo(packet)
-	case EMsg_ClientRequestWebAPIAuthenticateUserNonceResponse:
-		a.handleWebAPIUserNonce(packet)
-	case EMsg_ClientMarketingMessageUpdate:
-		a.handleMarketingMessageUpdate(packet)
+// Logs on as a game server, anonymously if there is no token. Like Steam's own
+// servers, anonymous ones send a ClientLogon and only those with a token a
+// ClientLogonGameServer.
+func (a *Auth) logOnGameServer(details GameServerLogOnDetails) {
+	a.mutex.Lock()
+	if !a.beginLogOn() {
+		a.mutex.Unlock()
+		return
 	}
+	a.details = nil
+	a.gameServer = &details
+	a.anonymous = false
+	a.mutex.Unlock()
+
+	logon := new(CMsgClientLogon)
+	logon.ProtocolVersion = proto.Uint32(MsgClientLogon_CurrentProtocol)
+	logon.GameServerAppId = proto.Int32(int32(details.AppId))
+
+	eMsg := EMsg(EMsg_ClientLogon)
+	accountType := EAccountType_AnonGameServer
+	if details.Token != "" {
+		logon.GameServerToken = proto.String(details.Token)
+		eMsg = EMsg_ClientLogonGameServer
+		accountType = EAccountType_GameServer
+	}
+	atomic.StoreUint64(&a.client.steamId, uint64(NewIdAdv(0, 0, int32(EUniverse_Public), int32(accountType))))
+
+	a.client.Write(NewClientMsgProtobuf(eMsg, logon))
+}
+
+// Logs off and waits until Steam confirmed it and the client disconnected, or until
+// ctx is done. The client still disconnects once Steam confirms it if ctx was done
+// first. Returns nil right away if the client isn't connected.
+func (a *Auth) LogOff(ctx context.Context) error {
+	if !a.client.Connected() {
+		return nil
+	}
+	a.mutex.Lock()
+	if a.loggedOff == nil {
+		a.loggedOff = make(chan struct{})
+	}
+	loggedOff := a.loggedOff
+	a.mutex.Unlock()
+
+	a.client.Write(NewClientMsgProtobuf(EMsg_ClientLogOff, new(CMsgClientLogOff)))
+	select {
+	case <-loggedOff:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Auth) loggingOff() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.loggedOff != nil
+}
+
+// Wakes up the callers of LogOff after the client disconnected.
+func (a *Auth) finishLogOff() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.loggedOff != nil {
+		close(a.loggedOff)
+		a.loggedOff = nil
+	}
+}
+
+func (a *Auth) registerHandlers(r *Router) {
+	r.Handle(EMsg_ClientLogOnResponse, a.handleLogOnResponse)
+	r.Handle(EMsg_ClientNewLoginKey, a.handleLoginKey)
+	r.Handle(EMsg_ClientLoggedOff, a.handleLoggedOff)
+	r.Handle(EMsg_ClientUpdateMachineAuth, a.handleUpdateMachineAuth)
+	r.Handle(EMsg_ClientAccountInfo, a.handleAccountInfo)
 }
 
 func (a *Auth) handleLogOnResponse(packet *PacketMsg) {
@@ -80,13 +307,47 @@ func (a *Auth) handleLogOnResponse(packet *PacketMsg) {
 
 	body := new(CMsgClientLogonResponse)
 	msg := packet.ReadProtoMsg(body)
+	a.setServerTime(body.GetRtime32ServerTime())
 
 	result := EResult(body.GetEresult())
+	a.mutex.Lock()
+	a.logOnConn = nil
+	loginKeyRejected := result == EResult_InvalidPassword && a.details != nil && a.details.LoginKey != ""
+	if loginKeyRejected {
+		details := *a.details
+		details.LoginKey = ""
+		a.details = &details
+	}
+	tokenRejected := (result == EResult_InvalidPassword || result == EResult_AccessDenied || result == EResult_Expired) &&
+		a.details != nil && a.details.RefreshToken != ""
+	if result == EResult_OK {
+		a.guardAttempts = 0
+		if a.details != nil {
+			// codes are only valid once
+			details := *a.details
+			details.AuthCode = ""
+			details.TwoFactorCode = ""
+			a.details = &details
+		}
+	}
+	a.mutex.Unlock()
+
+	if loginKeyRejected {
+		a.updateCredentials(a.accountName(), func(c *vault.Credentials) {
+			c.LoginKey = ""
+		})
+	}
+	if tokenRejected {
+		a.updateCredentials(a.accountName(), func(c *vault.Credentials) {
+			c.RefreshToken = ""
+		})
+	}
+
 	if result == EResult_OK {
 		atomic.StoreInt32(&a.client.sessionId, msg.Header.Proto.GetClientSessionid())
 		atomic.StoreUint64(&a.client.steamId, msg.Header.Proto.GetSteamid())
 
-		go a.client.heartbeatLoop(time.Duration(body.GetOutOfGameHeartbeatSeconds()))
+		a.client.startHeartbeat(time.Duration(body.GetOutOfGameHeartbeatSeconds()) * time.Second)
 
 		a.client.Emit(LoggedOnEvent{
 			Result:                    EResult(body.GetEresult()),
@@ -108,8 +369,15 @@ func (a *Auth) handleLogOnResponse(packet *PacketMsg) {
 			NumLoginFailuresToMigrate: body.GetCountLoginfailuresToMigrate(),
 			NumDisconnectsToMigrate:   body.GetCountDisconnectsToMigrate(),
 		})
+		a.client.reconnected()
 	} else if result == EResult_Fail || result == EResult_ServiceUnavailable || result == EResult_TryAnotherCM {
-		// some error on Steam's side, we'll get an EOF later
+		// some error on Steam's side, we'll get an EOF later unless we reconnect now
+		a.client.connectionLost(nil, fmt.Errorf("Logon failed: %v", result))
+	} else if loginKeyRejected {
+		a.client.Emit(LoginKeyRejectedEvent{Result: result})
+		a.client.Disconnect()
+	} else if isSteamGuardResult(result) {
+		a.handleSteamGuard(result, body.GetEmailDomain())
 	} else {
 		a.client.Fatalf("Login error: %v", result)
 	}
@@ -118,6 +386,20 @@ func (a *Auth) handleLogOnResponse(packet *PacketMsg) {
 func (a *Auth) handleLoginKey(packet *PacketMsg) {
 	body := new(CMsgClientNewLoginKey)
 	packet.ReadProtoMsg(body)
+	a.mutex.Lock()
+	remember := a.details != nil && (a.details.ShouldRememberPassword || a.details.LoginKey != "")
+	if remember {
+		// older keys become invalid
+		details := *a.details
+		details.LoginKey = body.GetLoginKey()
+		a.details = &details
+	}
+	a.mutex.Unlock()
+	if remember {
+		a.updateCredentials(a.accountName(), func(c *vault.Credentials) {
+			c.LoginKey = body.GetLoginKey()
+		})
+	}
 	a.client.Write(NewClientMsgProtobuf(EMsg_ClientNewLoginKeyAccepted, &CMsgClientNewLoginKeyAccepted{
 		UniqueId: proto.Uint32(body.GetUniqueId()),
 	}))
@@ -127,10 +409,6 @@ func (a *Auth) handleLoginKey(packet *PacketMsg) {
 	})
 }
 
-//TODO: handleSessionToken
-func (a *Auth) handleSessionToken(packet *PacketMsg) {
-}
-
 func (a *Auth) handleLoggedOff(packet *PacketMsg) {
 	result := EResult_Invalid
 	if packet.IsProto {
@@ -143,21 +421,63 @@ func (a *Auth) handleLoggedOff(packet *PacketMsg) {
 		result = body.Result
 	}
 	a.client.Emit(LoggedOffEvent{Result: result})
+	if a.loggingOff() {
+		a.client.Disconnect()
+	}
 }
 
 func (a *Auth) handleUpdateMachineAuth(packet *PacketMsg) {
 	body := new(CMsgClientUpdateMachineAuth)
-	hash := sha1.New()
-	hash.Write(body.GetBytes())
-	sha := hash.Sum(nil)
+	packet.ReadProtoMsg(body)
+
+	username := a.accountName()
+
+	// Steam may send the file in parts
+	part := body.GetBytes()
+	if n := int(body.GetCubtowrite()); body.Cubtowrite != nil && n < len(part) {
+		part = part[:n]
+	}
+	var sentry []byte
+	var err error
+	store := a.sentryStore()
+	if store != nil {
+		sentry, err = store.Load(username)
+	} else if body.GetOffset() > 0 {
+		// the earlier parts are gone, and hashing zeros instead would be wrong
+		err = fmt.Errorf("Can't write at offset %v without a SentryStore", body.GetOffset())
+	}
+	if err == nil {
+		sentry = writeSentry(sentry, int(body.GetOffset()), part)
+		if store != nil {
+			err = store.Save(username, sentry)
+		}
+	}
+	result := EResult_OK
+	if err != nil {
+		a.client.Errorf("Error storing the sentry file: %v", err)
+		result = EResult_Fail
+		sentry, part = nil, nil
+	}
+	sha := sentryHash(sentry)
 
 	msg := NewClientMsgProtobuf(EMsg_ClientUpdateMachineAuthResponse, &CMsgClientUpdateMachineAuthResponse{
-		ShaFile: sha,
+		Filename:      proto.String(body.GetFilename()),
+		Eresult:       proto.Uint32(uint32(result)),
+		Filesize:      proto.Uint32(uint32(len(sentry))),
+		ShaFile:       sha,
+		Getlasterror:  proto.Uint32(0),
+		Offset:        proto.Uint32(body.GetOffset()),
+		Cubwrote:      proto.Uint32(uint32(len(part))),
+		OtpType:       proto.Int32(int32(body.GetOtpType())),
+		OtpValue:      proto.Uint32(0),
+		OtpIdentifier: proto.String(body.GetOtpIdentifier()),
 	})
 	msg.SetTargetJobId(packet.SourceJobId)
 	a.client.Write(msg)
 
-	a.client.Emit(MachineAuthUpdateEvent{sha})
+	if result == EResult_OK {
+		a.client.Emit(MachineAuthUpdateEvent{sha})
+	}
 }
 
 func (a *Auth) handleAccountInfo(packet *PacketMsg) {
@@ -175,15 +495,3 @@ func (a *Auth) handleAccountInfo(packet *PacketMsg) {
 		FacebookName:         body.GetFacebookName(),
 	})
 }
-
-//TODO: handleWalletInfo
-func (a *Auth) handleWalletInfo(packet *PacketMsg) {
-}
-
-//TODO: handleWebAPIUserNonce
-func (a *Auth) handleWebAPIUserNonce(packet *PacketMsg) {
-}
-
-//TODO: handleMarketingMessageUpdate
-func (a *Auth) handleMarketingMessageUpdate(packet *PacketMsg) {
-}
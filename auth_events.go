@@ -31,6 +31,12 @@ type LoginKeyEvent struct {
 	LoginKey string
 }
 
+// Emitted if Steam rejected the login key passed to LogOn, e.g. because it expired
+// or a newer key was issued. Log on with the password to receive a new key.
+type LoginKeyRejectedEvent struct {
+	Result EResult
+}
+
 type LoggedOffEvent struct {
 	Result EResult
 }
@@ -0,0 +1,125 @@
+package steamgo_test
+
+import (
+	"context"
+	"github.com/gamingrobot/steamgo"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"github.com/gamingrobot/steamgo/testing/fakecm"
+	"testing"
+	"time"
+)
+
+func TestLogOnWithLoginKey(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	loggedOn := make(chan struct{}, 1)
+	client.Subscribe(func(steamgo.LoggedOnEvent) {
+		loggedOn <- struct{}{}
+	})
+
+	if err := client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", LoginKey: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loggedOn:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the LoggedOnEvent")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	packet, err := server.WaitFor(ctx, EMsg_ClientLogon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := new(CMsgClientLogon)
+	packet.ReadProtoMsg(body)
+	if body.GetLoginKey() != "key" || body.Password != nil || !body.GetShouldRememberPassword() {
+		t.Fatalf("Expected a logon with the login key, got %v", body)
+	}
+	closeClient(t, client, server)
+}
+
+func TestLoginKeyRejected(t *testing.T) {
+	server := fakecm.NewServer()
+	server.LogOnResult = EResult_InvalidPassword
+	startServer(t, server)
+	defer server.Close()
+	client := connect(t, server)
+	defer client.Close(context.Background())
+	rejected := make(chan steamgo.LoginKeyRejectedEvent, 1)
+	client.Subscribe(func(e steamgo.LoginKeyRejectedEvent) {
+		rejected <- e
+	})
+
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", LoginKey: "key"})
+	select {
+	case e := <-rejected:
+		if e.Result != EResult_InvalidPassword {
+			t.Fatalf("Unexpected result %v", e.Result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the LoginKeyRejectedEvent")
+	}
+	waitDisconnected(t, server)
+	if client.Connected() {
+		t.Fatal("Expected the client to disconnect")
+	}
+}
+
+func TestLogOnWithoutCredentials(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+
+	for _, details := range []steamgo.LogOnDetails{
+		{Username: "user"},
+		{Password: "password"},
+		{LoginKey: "key"},
+	} {
+		if err := client.Auth.LogOn(details); err != steamgo.ErrNoCredentials {
+			t.Errorf("Expected ErrNoCredentials for %+v, got %v", details, err)
+		}
+	}
+	closeClient(t, client, server)
+	if logOns := count(server, EMsg_ClientLogon); logOns != 0 {
+		t.Fatalf("Expected no logon, got %v", logOns)
+	}
+}
+
+func TestLogOnAnonymous(t *testing.T) {
+	tests := []struct {
+		logOn       func(*steamgo.Auth)
+		accountType int32
+		appId       int32
+	}{
+		{func(a *steamgo.Auth) { a.LogOnAnonymous() }, EAccountType_AnonUser, 0},
+		{func(a *steamgo.Auth) { a.LogOnAnonymousGameServer(440) }, EAccountType_AnonGameServer, 440},
+	}
+	for _, test := range tests {
+		server := startServer(t, fakecm.NewServer())
+		client := connect(t, server)
+		test.logOn(client.Auth)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		packet, err := server.WaitFor(ctx, EMsg_ClientLogon)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := new(CMsgClientLogon)
+		msg := packet.ReadProtoMsg(body)
+		if accountType := SteamId(msg.Header.Proto.GetSteamid()).GetAccountType(); accountType != test.accountType {
+			t.Errorf("Expected account type %v, got %v", test.accountType, accountType)
+		}
+		if body.AccountName != nil || body.Password != nil || body.LoginKey != nil || body.AccessToken != nil || body.GameServerToken != nil {
+			t.Errorf("Expected no credentials, got %v", body)
+		}
+		if body.GetGameServerAppId() != test.appId {
+			t.Errorf("Expected app id %v, got %v", test.appId, body.GetGameServerAppId())
+		}
+		closeClient(t, client, server)
+		server.Close()
+	}
+}
@@ -0,0 +1,405 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"github.com/gamingrobot/steamgo/vault"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// The methods of the Authentication service used by Authentication.
+// *Unified implements it.
+type authenticationService interface {
+	CallNonAuthed(ctx context.Context, method string, req, resp proto.Message) error
+}
+
+// Starts authentication sessions with the Authentication service, which issues the
+// refresh tokens modern Steam clients log on with instead of passwords. Sessions can
+// be started with a password or by scanning a QR code with the Steam mobile app.
+//
+// Authenticate after the ConnectedEvent and before logging on, then pass the
+// refresh token to Auth.LogOn as LogOnDetails.RefreshToken.
+type Authentication struct {
+	client  *Client
+	service authenticationService
+
+	// Asked for Steam Guard codes while waiting for a session. If nil, the
+	// CredentialProvider of the client's Auth is used.
+	CredentialProvider CredentialProvider
+}
+
+func newAuthentication(client *Client, service authenticationService) *Authentication {
+	return &Authentication{client: client, service: service}
+}
+
+// The time between two polls if Steam didn't specify one.
+const defaultAuthPollInterval = 5 * time.Second
+
+var (
+	ErrAuthSessionExpired = errors.New("Authentication session expired")
+	ErrMissingCredentials = errors.New("Username and password must be set")
+)
+
+type CredentialsAuthDetails struct {
+	Username string
+	Password string
+	// Shown in the account's list of authorized devices, defaults to "steamgo"
+	DeviceFriendlyName string
+	// Defaults to EAuthTokenPlatformType_k_EAuthTokenPlatformType_SteamClient
+	PlatformType EAuthTokenPlatformType
+	// Requests a refresh token that stays valid after the session ends
+	Persistent bool
+	// The NewGuardData of an earlier session, which lets Steam skip email codes
+	GuardData string
+}
+
+type QRAuthDetails struct {
+	// Shown in the account's list of authorized devices, defaults to "steamgo"
+	DeviceFriendlyName string
+	// Defaults to EAuthTokenPlatformType_k_EAuthTokenPlatformType_SteamClient
+	PlatformType EAuthTokenPlatformType
+}
+
+// A way Steam accepts to confirm an authentication session, e.g. an email code.
+type AuthConfirmation struct {
+	Type EAuthSessionGuardType
+	// For email codes, the domain of the email address
+	Message string
+}
+
+// The result of a finished authentication session. If the client's Auth has an
+// AccountStore, the refresh token and guard data are saved there.
+type AuthTokens struct {
+	AccountName string
+	// Authenticates Web API and community requests
+	AccessToken string
+	// Logs on as LogOnDetails.RefreshToken
+	RefreshToken string
+	// Pass as CredentialsAuthDetails.GuardData next time, if not empty
+	NewGuardData string
+}
+
+// A started authentication session. It must not be used from multiple goroutines at once.
+type AuthSession struct {
+	ClientId  uint64
+	RequestId []byte
+	// Unknown for QR sessions
+	SteamId SteamId `json:",string"`
+	// For QR sessions, the URL to show as a QR code
+	ChallengeUrl string
+	// Called when Steam replaced the ChallengeUrl while polling
+	ChallengeUrlChanged func(url string)
+	// Ordered by preference
+	AllowedConfirmations []AuthConfirmation
+	Interval             time.Duration
+
+	auth *Authentication
+}
+
+// Starts an authentication session with the account's password.
+func (a *Authentication) BeginWithCredentials(ctx context.Context, details *CredentialsAuthDetails) (*AuthSession, error) {
+	if len(details.Username) == 0 || len(details.Password) == 0 {
+		return nil, ErrMissingCredentials
+	}
+	guardData := details.GuardData
+	if guardData == "" {
+		guardData = a.storedGuardData(details.Username)
+	}
+	encryptedPassword, timestamp, err := a.encryptPassword(ctx, details.Username, details.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	persistence := ESessionPersistence_k_ESessionPersistence_Ephemeral
+	if details.Persistent {
+		persistence = ESessionPersistence_k_ESessionPersistence_Persistent
+	}
+	resp := new(CAuthentication_BeginAuthSessionViaCredentials_Response)
+	err = a.service.CallNonAuthed(ctx, "Authentication.BeginAuthSessionViaCredentials#1", &CAuthentication_BeginAuthSessionViaCredentials_Request{
+		DeviceFriendlyName:  proto.String(deviceFriendlyName(details.DeviceFriendlyName)),
+		AccountName:         proto.String(details.Username),
+		EncryptedPassword:   proto.String(encryptedPassword),
+		EncryptionTimestamp: proto.Uint64(timestamp),
+		RememberLogin:       proto.Bool(details.Persistent),
+		PlatformType:        platformType(details.PlatformType).Enum(),
+		Persistence:         persistence.Enum(),
+		GuardData:           stringOrNil(guardData),
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthSession{
+		ClientId:             resp.GetClientId(),
+		RequestId:            resp.GetRequestId(),
+		SteamId:              SteamId(resp.GetSteamid()),
+		AllowedConfirmations: allowedConfirmations(resp.GetAllowedConfirmations()),
+		Interval:             pollInterval(resp.GetInterval()),
+		auth:                 a,
+	}, nil
+}
+
+// Starts an authentication session which is approved by scanning the session's
+// ChallengeUrl as QR code with the Steam mobile app.
+func (a *Authentication) BeginWithQR(ctx context.Context, details *QRAuthDetails) (*AuthSession, error) {
+	resp := new(CAuthentication_BeginAuthSessionViaQR_Response)
+	err := a.service.CallNonAuthed(ctx, "Authentication.BeginAuthSessionViaQR#1", &CAuthentication_BeginAuthSessionViaQR_Request{
+		DeviceFriendlyName: proto.String(deviceFriendlyName(details.DeviceFriendlyName)),
+		PlatformType:       platformType(details.PlatformType).Enum(),
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthSession{
+		ClientId:             resp.GetClientId(),
+		RequestId:            resp.GetRequestId(),
+		ChallengeUrl:         resp.GetChallengeUrl(),
+		AllowedConfirmations: allowedConfirmations(resp.GetAllowedConfirmations()),
+		Interval:             pollInterval(resp.GetInterval()),
+		auth:                 a,
+	}, nil
+}
+
+// Returns the password encrypted with the account's RSA key and the key's timestamp.
+func (a *Authentication) encryptPassword(ctx context.Context, username, password string) (string, uint64, error) {
+	resp := new(CAuthentication_GetPasswordRSAPublicKey_Response)
+	err := a.service.CallNonAuthed(ctx, "Authentication.GetPasswordRSAPublicKey#1", &CAuthentication_GetPasswordRSAPublicKey_Request{
+		AccountName: proto.String(username),
+	}, resp)
+	if err != nil {
+		return "", 0, err
+	}
+	modulus, ok := new(big.Int).SetString(resp.GetPublickeyMod(), 16)
+	if !ok {
+		return "", 0, fmt.Errorf("Invalid RSA modulus %q", resp.GetPublickeyMod())
+	}
+	exponent, err := strconv.ParseInt(resp.GetPublickeyExp(), 16, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid RSA exponent %q", resp.GetPublickeyExp())
+	}
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &rsa.PublicKey{N: modulus, E: int(exponent)}, []byte(password))
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), resp.GetTimestamp(), nil
+}
+
+// Submits a Steam Guard code for the session. codeType is either
+// EAuthSessionGuardType_k_EAuthSessionGuardType_EmailCode or
+// EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceCode.
+func (s *AuthSession) SubmitCode(ctx context.Context, code string, codeType EAuthSessionGuardType) error {
+	err := s.auth.service.CallNonAuthed(ctx, "Authentication.UpdateAuthSessionWithSteamGuardCode#1", &CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request{
+		ClientId: proto.Uint64(s.ClientId),
+		Steamid:  proto.Uint64(uint64(s.SteamId)),
+		Code:     proto.String(code),
+		CodeType: codeType.Enum(),
+	}, nil)
+	if serr, ok := err.(*ServiceMethodError); ok && serr.Result == EResult_DuplicateRequest {
+		// the code was already accepted
+		return nil
+	}
+	return err
+}
+
+// Asks Steam once whether the session was confirmed. It returns nil tokens while it
+// is still pending and ErrAuthSessionExpired if it timed out.
+func (s *AuthSession) Poll(ctx context.Context) (*AuthTokens, error) {
+	resp := new(CAuthentication_PollAuthSessionStatus_Response)
+	err := s.auth.service.CallNonAuthed(ctx, "Authentication.PollAuthSessionStatus#1", &CAuthentication_PollAuthSessionStatus_Request{
+		ClientId:  proto.Uint64(s.ClientId),
+		RequestId: s.RequestId,
+	}, resp)
+	if serr, ok := err.(*ServiceMethodError); ok && serr.Result == EResult_FileNotFound {
+		return nil, ErrAuthSessionExpired
+	} else if err != nil {
+		return nil, err
+	}
+
+	if resp.NewClientId != nil {
+		s.ClientId = resp.GetNewClientId()
+	}
+	if resp.GetNewChallengeUrl() != "" && resp.GetNewChallengeUrl() != s.ChallengeUrl {
+		s.ChallengeUrl = resp.GetNewChallengeUrl()
+		if s.ChallengeUrlChanged != nil {
+			s.ChallengeUrlChanged(s.ChallengeUrl)
+		}
+	}
+	if resp.GetRefreshToken() == "" {
+		return nil, nil
+	}
+	tokens := &AuthTokens{
+		AccountName:  resp.GetAccountName(),
+		AccessToken:  resp.GetAccessToken(),
+		RefreshToken: resp.GetRefreshToken(),
+		NewGuardData: resp.GetNewGuardData(),
+	}
+	s.auth.storeTokens(tokens)
+	return tokens, nil
+}
+
+// Confirms the session and waits until Steam issued its tokens. If Steam prefers a
+// code, it is requested from the CredentialProvider and submitted, otherwise this polls
+// until the session was confirmed on another device, e.g. in the mobile app. Without
+// a CredentialProvider, it also polls if the session may be confirmed that way.
+func (s *AuthSession) Wait(ctx context.Context) (*AuthTokens, error) {
+	if len(s.AllowedConfirmations) > 0 && (s.auth.credentialProvider() != nil || !s.confirmableOutOfBand()) {
+		if err := s.submitProviderCode(ctx, s.AllowedConfirmations[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		tokens, err := s.Poll(ctx)
+		if tokens != nil || err != nil {
+			return tokens, err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Returns whether the session may be confirmed without entering a code.
+func (s *AuthSession) confirmableOutOfBand() bool {
+	for _, confirmation := range s.AllowedConfirmations {
+		switch confirmation.Type {
+		case EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceConfirmation,
+			EAuthSessionGuardType_k_EAuthSessionGuardType_EmailConfirmation:
+			return true
+		}
+	}
+	return false
+}
+
+// Asks the CredentialProvider for the code the confirmation requires, if any,
+// and submits it.
+func (s *AuthSession) submitProviderCode(ctx context.Context, confirmation AuthConfirmation) error {
+	var getCode func(previousIncorrect bool) (string, error)
+	var wrongCode EResult
+	guardErr := &SteamGuardError{}
+	provider := s.auth.credentialProvider()
+	switch confirmation.Type {
+	case EAuthSessionGuardType_k_EAuthSessionGuardType_EmailCode:
+		guardErr.Result = EResult_AccountLogonDenied
+		guardErr.EmailDomain = confirmation.Message
+		wrongCode = EResult_InvalidLoginAuthCode
+		if provider != nil {
+			getCode = func(previousIncorrect bool) (string, error) {
+				return provider.EmailCode(confirmation.Message, previousIncorrect)
+			}
+		}
+	case EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceCode:
+		guardErr.Result = EResult_AccountLoginDeniedNeedTwoFactor
+		guardErr.TwoFactor = true
+		wrongCode = EResult_TwoFactorCodeMismatch
+		if provider != nil {
+			getCode = provider.TwoFactorCode
+		}
+	default:
+		return nil
+	}
+	if getCode == nil {
+		return guardErr
+	}
+
+	for attempt := 0; attempt < maxSteamGuardAttempts; attempt++ {
+		code, err := getCode(attempt > 0)
+		if err != nil {
+			guardErr.Err = err
+			return guardErr
+		}
+		err = s.SubmitCode(ctx, code, confirmation.Type)
+		if serr, ok := err.(*ServiceMethodError); ok && serr.Result == wrongCode {
+			guardErr.Result = wrongCode
+			continue
+		}
+		return err
+	}
+	return guardErr
+}
+
+// Returns the guard data saved in the client's AccountStore, if any.
+func (a *Authentication) storedGuardData(username string) string {
+	if a.client == nil || a.client.Auth.AccountStore == nil {
+		return ""
+	}
+	credentials, err := a.client.Auth.AccountStore.Load(username)
+	if err != nil {
+		a.client.Errorf("Error loading the credentials of %v: %v", username, err)
+		return ""
+	}
+	return credentials.GuardData
+}
+
+// Saves the refresh token and guard data in the client's AccountStore, if any.
+func (a *Authentication) storeTokens(tokens *AuthTokens) {
+	if a.client == nil {
+		return
+	}
+	a.client.Auth.updateCredentials(tokens.AccountName, func(c *vault.Credentials) {
+		c.RefreshToken = tokens.RefreshToken
+		if tokens.NewGuardData != "" {
+			c.GuardData = tokens.NewGuardData
+		}
+	})
+}
+
+func (a *Authentication) credentialProvider() CredentialProvider {
+	if a.CredentialProvider != nil {
+		return a.CredentialProvider
+	}
+	if a.client != nil {
+		return a.client.Auth.CredentialProvider
+	}
+	return nil
+}
+
+func deviceFriendlyName(name string) string {
+	if name == "" {
+		return "steamgo"
+	}
+	return name
+}
+
+func platformType(platform EAuthTokenPlatformType) EAuthTokenPlatformType {
+	if platform == EAuthTokenPlatformType_k_EAuthTokenPlatformType_Unknown {
+		return EAuthTokenPlatformType_k_EAuthTokenPlatformType_SteamClient
+	}
+	return platform
+}
+
+func stringOrNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func pollInterval(seconds float32) time.Duration {
+	if seconds <= 0 {
+		return defaultAuthPollInterval
+	}
+	// rounded, since the float32 isn't exact
+	return time.Duration(math.Round(float64(seconds)*1000)) * time.Millisecond
+}
+
+func allowedConfirmations(confirmations []*CAuthentication_AllowedConfirmation) []AuthConfirmation {
+	result := make([]AuthConfirmation, 0, len(confirmations))
+	for _, c := range confirmations {
+		result = append(result, AuthConfirmation{c.GetConfirmationType(), c.GetAssociatedMessage()})
+	}
+	return result
+}
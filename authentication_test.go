@@ -0,0 +1,257 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A local Authentication service for one account with a mobile authenticator.
+type fakeAuthService struct {
+	t        *testing.T
+	key      *rsa.PrivateKey
+	password string
+	code     string
+	// allowed for sessions started with credentials
+	confirmations []EAuthSessionGuardType
+
+	mutex     sync.Mutex
+	confirmed bool
+	polls     int
+	calls     []string
+}
+
+func newFakeAuthService(t *testing.T) *fakeAuthService {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeAuthService{
+		t:             t,
+		key:           key,
+		password:      "hunter2",
+		code:          "ABCDE",
+		confirmations: []EAuthSessionGuardType{EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceCode},
+	}
+}
+
+func (f *fakeAuthService) CallNonAuthed(ctx context.Context, method string, req, resp proto.Message) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, method)
+	switch method {
+	case "Authentication.GetPasswordRSAPublicKey#1":
+		r := resp.(*CAuthentication_GetPasswordRSAPublicKey_Response)
+		r.PublickeyMod = proto.String(f.key.N.Text(16))
+		r.PublickeyExp = proto.String(fmt.Sprintf("%x", f.key.E))
+		r.Timestamp = proto.Uint64(1234)
+	case "Authentication.BeginAuthSessionViaCredentials#1":
+		q := req.(*CAuthentication_BeginAuthSessionViaCredentials_Request)
+		encrypted, _ := base64.StdEncoding.DecodeString(q.GetEncryptedPassword())
+		password, err := rsa.DecryptPKCS1v15(nil, f.key, encrypted)
+		if err != nil || string(password) != f.password || q.GetEncryptionTimestamp() != 1234 {
+			return &ServiceMethodError{Method: method, Result: EResult_InvalidPassword}
+		}
+		r := resp.(*CAuthentication_BeginAuthSessionViaCredentials_Response)
+		r.ClientId = proto.Uint64(1)
+		r.RequestId = []byte("request")
+		r.Steamid = proto.Uint64(76561197960287930)
+		r.Interval = proto.Float32(0.01)
+		for _, confirmation := range f.confirmations {
+			r.AllowedConfirmations = append(r.AllowedConfirmations, &CAuthentication_AllowedConfirmation{
+				ConfirmationType: confirmation.Enum(),
+			})
+		}
+	case "Authentication.BeginAuthSessionViaQR#1":
+		r := resp.(*CAuthentication_BeginAuthSessionViaQR_Response)
+		r.ClientId = proto.Uint64(1)
+		r.RequestId = []byte("request")
+		r.ChallengeUrl = proto.String("https://s.team/q/1/1")
+		r.Interval = proto.Float32(0.01)
+		r.AllowedConfirmations = []*CAuthentication_AllowedConfirmation{{
+			ConfirmationType: EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceConfirmation.Enum(),
+		}}
+	case "Authentication.UpdateAuthSessionWithSteamGuardCode#1":
+		q := req.(*CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request)
+		if q.GetCode() != f.code {
+			return &ServiceMethodError{Method: method, Result: EResult_TwoFactorCodeMismatch}
+		}
+		f.confirmed = true
+	case "Authentication.PollAuthSessionStatus#1":
+		r := resp.(*CAuthentication_PollAuthSessionStatus_Response)
+		f.polls++
+		if f.polls == 1 {
+			r.NewClientId = proto.Uint64(2)
+			r.NewChallengeUrl = proto.String("https://s.team/q/1/2")
+			return nil
+		}
+		// QR sessions are confirmed in the app after the second poll
+		if f.polls > 2 {
+			f.confirmed = true
+		}
+		if f.confirmed {
+			r.AccountName = proto.String("account")
+			r.AccessToken = proto.String("access")
+			r.RefreshToken = proto.String("refresh")
+		}
+	default:
+		f.t.Errorf("Unexpected call to %v", method)
+	}
+	return nil
+}
+
+type fakeCredentialProvider struct {
+	codes []string
+	asked []bool
+}
+
+func (p *fakeCredentialProvider) EmailCode(emailDomain string, previousIncorrect bool) (string, error) {
+	return "", fmt.Errorf("Unexpected email code request")
+}
+
+func (p *fakeCredentialProvider) TwoFactorCode(previousIncorrect bool) (string, error) {
+	p.asked = append(p.asked, previousIncorrect)
+	code := p.codes[0]
+	p.codes = p.codes[1:]
+	return code, nil
+}
+
+func TestAuthenticationWithCredentials(t *testing.T) {
+	service := newFakeAuthService(t)
+	provider := &fakeCredentialProvider{codes: []string{"WRONG", "ABCDE"}}
+	auth := newAuthentication(nil, service)
+	auth.CredentialProvider = provider
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := auth.BeginWithCredentials(ctx, &CredentialsAuthDetails{Username: "account", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.SteamId != 76561197960287930 || session.Interval != 10*time.Millisecond {
+		t.Fatalf("Wrong session %+v", session)
+	}
+	tokens, err := session.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh" || tokens.AccountName != "account" {
+		t.Fatalf("Wrong tokens %+v", tokens)
+	}
+	if len(provider.asked) != 2 || provider.asked[0] || !provider.asked[1] {
+		t.Fatalf("Expected the code to be asked for again after a mismatch, got %v", provider.asked)
+	}
+	if session.ClientId != 2 {
+		t.Fatalf("Expected the new client id, got %v", session.ClientId)
+	}
+}
+
+func TestAuthenticationWrongPassword(t *testing.T) {
+	auth := newAuthentication(nil, newFakeAuthService(t))
+	_, err := auth.BeginWithCredentials(context.Background(), &CredentialsAuthDetails{Username: "account", Password: "wrong"})
+	if serr, ok := err.(*ServiceMethodError); !ok || serr.Result != EResult_InvalidPassword {
+		t.Fatalf("Expected InvalidPassword, got %v", err)
+	}
+}
+
+func TestAuthenticationWithoutCredentials(t *testing.T) {
+	auth := newAuthentication(nil, newFakeAuthService(t))
+	for _, details := range []*CredentialsAuthDetails{{Username: "account"}, {Password: "hunter2"}} {
+		if _, err := auth.BeginWithCredentials(context.Background(), details); err != ErrMissingCredentials {
+			t.Fatalf("Expected ErrMissingCredentials for %+v, got %v", details, err)
+		}
+	}
+}
+
+func TestAuthenticationWithoutCredentialProvider(t *testing.T) {
+	auth := newAuthentication(nil, newFakeAuthService(t))
+	ctx := context.Background()
+	session, err := auth.BeginWithCredentials(ctx, &CredentialsAuthDetails{Username: "account", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.Wait(ctx)
+	if gerr, ok := err.(*SteamGuardError); !ok || !gerr.TwoFactor {
+		t.Fatalf("Expected a two-factor SteamGuardError, got %v", err)
+	}
+}
+
+func TestAuthenticationFallsBackToConfirmation(t *testing.T) {
+	service := newFakeAuthService(t)
+	service.confirmations = append(service.confirmations, EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceConfirmation)
+	auth := newAuthentication(nil, service)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := auth.BeginWithCredentials(ctx, &CredentialsAuthDetails{Username: "account", Password: "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a CredentialProvider, the session is confirmed in the app
+	tokens, err := session.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh" {
+		t.Fatalf("Wrong tokens %+v", tokens)
+	}
+	for _, call := range service.calls {
+		if call == "Authentication.UpdateAuthSessionWithSteamGuardCode#1" {
+			t.Fatal("Expected no code to be submitted")
+		}
+	}
+}
+
+func TestAuthenticationWithQR(t *testing.T) {
+	service := newFakeAuthService(t)
+	auth := newAuthentication(nil, service)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	session, err := auth.BeginWithQR(ctx, &QRAuthDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ChallengeUrl != "https://s.team/q/1/1" {
+		t.Fatalf("Wrong challenge URL %v", session.ChallengeUrl)
+	}
+	var changed []string
+	session.ChallengeUrlChanged = func(url string) {
+		changed = append(changed, url)
+	}
+	tokens, err := session.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.RefreshToken != "refresh" {
+		t.Fatalf("Wrong tokens %+v", tokens)
+	}
+	if len(changed) != 1 || changed[0] != "https://s.team/q/1/2" {
+		t.Fatalf("Expected one challenge URL change, got %v", changed)
+	}
+	for _, call := range service.calls {
+		if call == "Authentication.UpdateAuthSessionWithSteamGuardCode#1" {
+			t.Fatal("QR sessions must not submit codes")
+		}
+	}
+}
+
+func TestLogOnWithRefreshToken(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+
+	client.Auth.LogOn(LogOnDetails{Username: "account", RefreshToken: "refresh"})
+	body := new(CMsgClientLogon)
+	conn.expect(t, EMsg_ClientLogon).ReadProtoMsg(body)
+	if body.GetAccessToken() != "refresh" || body.Password != nil {
+		t.Fatalf("Expected a logon with the refresh token, got %v", body)
+	}
+}
@@ -0,0 +1,285 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+var ErrNoGameConnectTokens = errors.New("No game connect tokens left")
+
+// Creates the session tickets a client presents to secured game servers.
+//
+// Steam sends game connect tokens after logging on. Every ticket consumes one of
+// them and is valid until it is cancelled or the client disconnects. Disconnecting
+// also drops the cached app ownership tickets.
+type AuthTickets struct {
+	client *Client
+	start  time.Time
+
+	mutex       sync.Mutex // guarding all following fields
+	tokens      [][]byte   // game connect tokens, oldest first
+	maxTokens   int
+	ownership   map[uint32][]byte // app ownership tickets by app id
+	tickets     []*AuthTicket     // active tickets
+	sequence    uint32            // of the last auth list we sent
+	connections uint32
+}
+
+// A session ticket registered with Steam.
+type AuthTicket struct {
+	AppId uint32
+	// The ticket to send to the game server
+	Ticket []byte
+	// Identifies the ticket
+	Crc uint32
+
+	tickets   *AuthTickets
+	authToken []byte        // the part of the ticket registered with Steam
+	acked     chan struct{} // closed once Steam acknowledged the ticket
+	dropped   chan struct{} // closed once the ticket was cancelled or the client disconnected
+}
+
+func newAuthTickets(client *Client) *AuthTickets {
+	return &AuthTickets{
+		client:    client,
+		start:     time.Now(),
+		maxTokens: 10,
+		ownership: make(map[uint32][]byte),
+	}
+}
+
+// Returns the number of game connect tokens left.
+func (a *AuthTickets) TokensLeft() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.tokens)
+}
+
+// Builds a session ticket for the given app and registers it with Steam.
+// It returns once Steam acknowledged the ticket, so that game servers can verify it.
+// Like a job, it fails with ErrJobTimeout if Steam doesn't answer within the client's
+// JobTimeout and with ErrJobAborted if the client disconnects first.
+func (a *AuthTickets) GetSessionTicket(ctx context.Context, appId uint32) (*AuthTicket, error) {
+	appTicket, err := a.appOwnershipTicket(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	if len(a.tokens) == 0 {
+		a.mutex.Unlock()
+		return nil, ErrNoGameConnectTokens
+	}
+	token := a.tokens[0]
+	a.tokens = a.tokens[1:]
+	a.connections++
+	authToken := a.buildAuthToken(token)
+	ticket := &AuthTicket{
+		AppId:     appId,
+		Ticket:    append(append([]byte(nil), authToken...), appTicketSection(appTicket)...),
+		Crc:       crc32.ChecksumIEEE(authToken),
+		tickets:   a,
+		authToken: authToken,
+		acked:     make(chan struct{}),
+		dropped:   make(chan struct{}),
+	}
+	a.tickets = append(a.tickets, ticket)
+	a.mutex.Unlock()
+
+	a.sendAuthList()
+
+	timeout := a.client.JobTimeout
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ticket.acked:
+		return ticket, nil
+	case <-ticket.dropped:
+		return nil, ErrJobAborted
+	case <-timer.C:
+		ticket.Cancel()
+		return nil, ErrJobTimeout
+	case <-ctx.Done():
+		ticket.Cancel()
+		return nil, ctx.Err()
+	}
+}
+
+// Stops the ticket from being accepted by game servers.
+func (t *AuthTicket) Cancel() {
+	a := t.tickets
+	a.mutex.Lock()
+	found := false
+	for i, other := range a.tickets {
+		if other == t {
+			a.tickets = append(a.tickets[:i:i], a.tickets[i+1:]...)
+			close(t.dropped)
+			found = true
+			break
+		}
+	}
+	a.mutex.Unlock()
+	if found {
+		a.sendAuthList()
+	}
+}
+
+// Returns the game connect token followed by the session header.
+// The mutex must be held.
+func (a *AuthTickets) buildAuthToken(token []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(len(token)))
+	buf.Write(token)
+
+	const sessionSize = 6 * 4
+	binary.Write(buf, binary.LittleEndian, uint32(sessionSize))
+	binary.Write(buf, binary.LittleEndian, uint32(1))
+	binary.Write(buf, binary.LittleEndian, uint32(2))
+	random := make([]byte, 8)
+	rand.Read(random)
+	buf.Write(random)
+	binary.Write(buf, binary.LittleEndian, uint32(time.Since(a.start)/time.Millisecond))
+	binary.Write(buf, binary.LittleEndian, a.connections)
+	return buf.Bytes()
+}
+
+func appTicketSection(appTicket []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(len(appTicket)))
+	buf.Write(appTicket)
+	return buf.Bytes()
+}
+
+// Returns the cached app ownership ticket or requests it.
+func (a *AuthTickets) appOwnershipTicket(ctx context.Context, appId uint32) ([]byte, error) {
+	a.mutex.Lock()
+	ticket, ok := a.ownership[appId]
+	a.mutex.Unlock()
+	if ok {
+		return ticket, nil
+	}
+
+	packet, err := a.client.Request(ctx, NewClientMsgProtobuf(EMsg_ClientGetAppOwnershipTicket, &CMsgClientGetAppOwnershipTicket{
+		AppId: proto.Uint32(appId),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	body := new(CMsgClientGetAppOwnershipTicketResponse)
+	packet.ReadProtoMsg(body)
+	if result := EResult(body.GetEresult()); result != EResult_OK {
+		return nil, fmt.Errorf("Error getting the app ownership ticket for %v: %v", appId, result)
+	}
+
+	a.mutex.Lock()
+	a.ownership[appId] = body.GetTicket()
+	a.mutex.Unlock()
+	return body.GetTicket(), nil
+}
+
+// Requests an encrypted app ticket for the given app containing userData.
+// It returns the serialized ticket, which backends holding the app's encryption
+// key can verify with cryptoutil.DecryptEncryptedAppTicket.
+func (a *AuthTickets) RequestEncryptedAppTicket(ctx context.Context, appId uint32, userData []byte) ([]byte, error) {
+	packet, err := a.client.Request(ctx, NewClientMsgProtobuf(EMsg_ClientRequestEncryptedAppTicket, &CMsgClientRequestEncryptedAppTicket{
+		AppId:    proto.Uint32(appId),
+		Userdata: userData,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	body := new(CMsgClientRequestEncryptedAppTicketResponse)
+	packet.ReadProtoMsg(body)
+	if result := EResult(body.GetEresult()); result != EResult_OK {
+		return nil, fmt.Errorf("Error getting the encrypted app ticket for %v: %v", appId, result)
+	}
+	if body.EncryptedAppTicket == nil {
+		return nil, fmt.Errorf("Got no encrypted app ticket for %v", appId)
+	}
+	return proto.Marshal(body.EncryptedAppTicket)
+}
+
+// Sends the list of all active tickets.
+func (a *AuthTickets) sendAuthList() {
+	a.mutex.Lock()
+	a.sequence++
+	list := &CMsgClientAuthList{
+		TokensLeft:      proto.Uint32(uint32(len(a.tokens))),
+		LastRequestSeq:  proto.Uint32(a.sequence),
+		MessageSequence: proto.Uint32(a.sequence),
+	}
+	seen := make(map[uint32]bool)
+	for _, ticket := range a.tickets {
+		list.Tickets = append(list.Tickets, &CMsgAuthTicket{
+			Gameid:    proto.Uint64(uint64(ticket.AppId)),
+			TicketCrc: proto.Uint32(ticket.Crc),
+			Ticket:    ticket.authToken,
+		})
+		if !seen[ticket.AppId] {
+			seen[ticket.AppId] = true
+			list.AppIds = append(list.AppIds, ticket.AppId)
+		}
+	}
+	a.mutex.Unlock()
+
+	a.client.Write(NewClientMsgProtobuf(EMsg_ClientAuthList, list))
+}
+
+// Drops the active tickets and the cached app ownership tickets, which are only
+// valid for the session. Called after the connection was closed, so that the next
+// auth list doesn't contain them.
+func (a *AuthTickets) reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, ticket := range a.tickets {
+		close(ticket.dropped)
+	}
+	a.tickets = nil
+	a.ownership = make(map[uint32][]byte)
+}
+
+func (a *AuthTickets) registerHandlers(r *Router) {
+	r.HandleBody(EMsg_ClientGameConnectTokens, a.handleGameConnectTokens)
+	r.HandleBody(EMsg_ClientAuthListAck, a.handleAuthListAck)
+}
+
+func (a *AuthTickets) handleGameConnectTokens(packet *PacketMsg, body *CMsgClientGameConnectTokens) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	// keep the previous limit if Steam didn't send one
+	if max := int(body.GetMaxTokensToKeep()); max > 0 {
+		a.maxTokens = max
+	}
+	a.tokens = append(a.tokens, body.GetTokens()...)
+	if len(a.tokens) > a.maxTokens {
+		a.tokens = a.tokens[len(a.tokens)-a.maxTokens:]
+	}
+}
+
+func (a *AuthTickets) handleAuthListAck(packet *PacketMsg, body *CMsgClientAuthListAck) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, crc := range body.GetTicketCrc() {
+		for _, ticket := range a.tickets {
+			if ticket.Crc == crc {
+				select {
+				case <-ticket.acked:
+				default:
+					close(ticket.acked)
+				}
+			}
+		}
+	}
+}
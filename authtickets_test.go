@@ -0,0 +1,233 @@
+package steamgo_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/gamingrobot/steamgo"
+	"github.com/gamingrobot/steamgo/cryptoutil"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/testing/fakecm"
+	"testing"
+	"time"
+)
+
+// A server handing out game connect tokens after every logon.
+func ticketServer(t *testing.T, ignore ...EMsg) *fakecm.Server {
+	server := fakecm.NewServer()
+	server.Script = []fakecm.Fixture{{
+		After:    EMsg_ClientLogon,
+		Messages: []IMsg{fakecm.GameConnectTokens([]byte("token 1"), []byte("token 2"))},
+	}}
+	server.Ignore = ignore
+	return startServer(t, server)
+}
+
+// Logs on and waits until the client got its game connect tokens.
+func logOnWithTokens(t *testing.T, client *steamgo.Client) {
+	logOn(t, client)
+	deadline := time.Now().Add(5 * time.Second)
+	for client.AuthTickets.TokensLeft() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the game connect tokens")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func getTicket(t *testing.T, client *steamgo.Client, appId uint32) *steamgo.AuthTicket {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ticket, err := client.AuthTickets.GetSessionTicket(ctx, appId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticket
+}
+
+// Returns the CRCs of the tickets in each auth list the server received.
+func authLists(server *fakecm.Server) [][]uint32 {
+	var lists [][]uint32
+	for _, packet := range server.Received() {
+		if packet.EMsg == EMsg_ClientAuthList {
+			body := new(CMsgClientAuthList)
+			packet.ReadProtoMsg(body)
+			crcs := []uint32{}
+			for _, ticket := range body.GetTickets() {
+				crcs = append(crcs, ticket.GetTicketCrc())
+			}
+			lists = append(lists, crcs)
+		}
+	}
+	return lists
+}
+
+// Closes the client, so that everything it sent was received.
+func closeClient(t *testing.T, client *steamgo.Client, server *fakecm.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitDisconnected(t, server)
+}
+
+func TestSessionTicket(t *testing.T) {
+	server := ticketServer(t)
+	defer server.Close()
+	client := connect(t, server)
+	logOnWithTokens(t, client)
+
+	ticket := getTicket(t, client, 440)
+	if ticket.AppId != 440 || !bytes.HasSuffix(ticket.Ticket, fakecm.AppOwnershipTicket(440)) {
+		t.Fatalf("Unexpected ticket %+v", ticket)
+	}
+	if !bytes.Contains(ticket.Ticket, []byte("token 1")) {
+		t.Fatal("Expected the ticket to contain the oldest game connect token")
+	}
+	if left := client.AuthTickets.TokensLeft(); left != 1 {
+		t.Fatalf("Expected one token to be left, got %v", left)
+	}
+
+	// the ownership ticket is cached
+	getTicket(t, client, 440)
+	if requests := count(server, EMsg_ClientGetAppOwnershipTicket); requests != 1 {
+		t.Fatalf("Expected one ownership ticket request, got %v", requests)
+	}
+	if _, err := client.AuthTickets.GetSessionTicket(context.Background(), 440); err != steamgo.ErrNoGameConnectTokens {
+		t.Fatalf("Expected ErrNoGameConnectTokens, got %v", err)
+	}
+	closeClient(t, client, server)
+}
+
+func TestCancelSessionTicket(t *testing.T) {
+	server := ticketServer(t)
+	defer server.Close()
+	client := connect(t, server)
+	logOnWithTokens(t, client)
+
+	first := getTicket(t, client, 440)
+	second := getTicket(t, client, 570)
+	first.Cancel()
+	first.Cancel()
+	closeClient(t, client, server)
+
+	lists := authLists(server)
+	if len(lists) != 3 {
+		t.Fatalf("Expected an auth list per change, got %v", lists)
+	}
+	if last := lists[2]; len(last) != 1 || last[0] != second.Crc {
+		t.Fatalf("Expected only the second ticket to be left, got %v", last)
+	}
+}
+
+func TestSessionTicketTimeout(t *testing.T) {
+	server := ticketServer(t, EMsg_ClientAuthList)
+	defer server.Close()
+	client := connect(t, server)
+	client.JobTimeout = 50 * time.Millisecond
+	logOnWithTokens(t, client)
+
+	if _, err := client.AuthTickets.GetSessionTicket(context.Background(), 440); err != steamgo.ErrJobTimeout {
+		t.Fatalf("Expected ErrJobTimeout, got %v", err)
+	}
+	closeClient(t, client, server)
+	if lists := authLists(server); len(lists) != 2 || len(lists[1]) != 0 {
+		t.Fatalf("Expected the ticket to be cancelled, got %v", lists)
+	}
+}
+
+func TestSessionTicketAbortedByDisconnect(t *testing.T) {
+	server := ticketServer(t, EMsg_ClientAuthList)
+	defer server.Close()
+	client := connect(t, server)
+	logOnWithTokens(t, client)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.AuthTickets.GetSessionTicket(context.Background(), 440)
+		errs <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := server.WaitFor(ctx, EMsg_ClientAuthList); err != nil {
+		t.Fatal(err)
+	}
+	client.Disconnect()
+
+	select {
+	case err := <-errs:
+		if err != steamgo.ErrJobAborted {
+			t.Fatalf("Expected ErrJobAborted, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetSessionTicket didn't return after the disconnect")
+	}
+	closeClient(t, client, server)
+}
+
+func TestSessionTicketsEndWithTheSession(t *testing.T) {
+	server := ticketServer(t)
+	defer server.Close()
+	client := connect(t, server)
+	logOnWithTokens(t, client)
+	getTicket(t, client, 440)
+
+	client.Disconnect()
+	waitDisconnected(t, server)
+	connectClient(t, client, server)
+	logOnWithTokens(t, client)
+	ticket := getTicket(t, client, 440)
+	closeClient(t, client, server)
+
+	if requests := count(server, EMsg_ClientGetAppOwnershipTicket); requests != 2 {
+		t.Fatalf("Expected the ownership ticket to be requested again, got %v requests", requests)
+	}
+	lists := authLists(server)
+	if last := lists[len(lists)-1]; len(last) != 1 || last[0] != ticket.Crc {
+		t.Fatalf("Expected only the new ticket in the auth list, got %v", last)
+	}
+}
+
+func TestGameConnectTokensWithoutLimit(t *testing.T) {
+	tokens := make([][]byte, 12)
+	for i := range tokens {
+		tokens[i] = []byte{byte(i)}
+	}
+	server := fakecm.NewServer()
+	server.Script = []fakecm.Fixture{{
+		After:    EMsg_ClientLogon,
+		Messages: []IMsg{NewClientMsgProtobuf(EMsg_ClientGameConnectTokens, &CMsgClientGameConnectTokens{Tokens: tokens})},
+	}}
+	startServer(t, server)
+	defer server.Close()
+	client := connect(t, server)
+	logOnWithTokens(t, client)
+
+	// the default limit is kept
+	if left := client.AuthTickets.TokensLeft(); left != 10 {
+		t.Fatalf("Expected 10 tokens, got %v", left)
+	}
+	closeClient(t, client, server)
+}
+
+func TestRequestEncryptedAppTicket(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	logOn(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ticket, err := client.AuthTickets.RequestEncryptedAppTicket(ctx, 440, []byte("user data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := cryptoutil.DecryptEncryptedAppTicket(ticket, fakecm.AppTicketKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.AppId != 440 || decrypted.SteamId != fakecm.DefaultSteamId || string(decrypted.UserData) != "user data" {
+		t.Fatalf("Unexpected ticket %+v", decrypted)
+	}
+	closeClient(t, client, server)
+}
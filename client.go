@@ -3,8 +3,10 @@ package steamgo
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/gamingrobot/steamgo/connection"
 	"github.com/gamingrobot/steamgo/cryptoutil"
@@ -14,43 +16,86 @@ import (
 	. "github.com/gamingrobot/steamgo/steamid"
 	"hash/crc32"
 	"io/ioutil"
-	"log"
-	//"reflect"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // Represents a client to the Steam network.
-// Always poll events from the channel returned by Events() or receiving messages will stop.
+// Receive events either with Subscribe or by polling the channel returned by Events().
+// Once Events() was called, always poll it or receiving messages will stop.
 // All access, unless otherwise noted, should be threadsafe.
 //
 // When a FatalError is emitted, the connection is automatically closed. The same client can be used to reconnect.
 // Other errors don't have any effect.
 type Client struct {
-	Auth    *Auth
-	Social  *Social
-	Web     *Web
-	Trading *Trading
-	GC      *GameCoordinator
+	Auth        *Auth
+	Social      *Social
+	Web         *Web
+	Trading     *Trading
+	GC          *GameCoordinator
+	Unified     *Unified
+	AuthTickets *AuthTickets
+	Account     *Account
+	// Authenticates before logging on
+	Authentication *Authentication
+	// Only used by game servers
+	GameServer *GameServer
+	// Dispatches incoming packets. Register handlers here to extend the client.
+	Router *Router
 
 	sessionId int32
 	steamId   uint64
 
 	currentJobId uint64
+	jobs         *jobManager
 
-	events   chan interface{}
-	handlers []PacketHandler
-
-	tempSessionKey []byte
+	events     chan interface{}
+	eventsSub  *Subscription // feeding events
+	eventsOnce sync.Once
+	eventsDone chan struct{} // closed by Close to stop eventsSub
+	closeOnce  sync.Once
+	bus        *eventBus
 
+	// Limits the time it takes to establish a connection, including the encryption
+	// handshake in ConnectContext. Zero means no limit.
 	ConnectionTimeout time.Duration
-
-	mutex     sync.RWMutex // guarding connection, heartbeat and writeChan
-	conn      connection.Connection
-	writeChan chan IMsg
-	writeBuf  *bytes.Buffer
-	heartbeat *time.Ticker
+	// Opens the connections to Steam, including the web requests made by Web.
+	// Set it to route this client through a proxy, e.g. with connection.NewProxyDialer.
+	// If nil, connection.DefaultDialer is used.
+	Dialer connection.Dialer
+	// The known CM servers. Connect picks the healthiest one and the outcome of every
+	// connection attempt is recorded here. Servers sent by Steam are added to it.
+	// If nil, the list shared by all clients, servers.Default(), is used. Set
+	// servers.DefaultStore to keep it between runs.
+	Servers *servers.ServerList
+	// The time a job may go without a response or heartbeat before it fails with
+	// ErrJobTimeout. If zero, DefaultJobTimeout is used.
+	JobTimeout time.Duration
+	// Receives every packet after decryption and every message before encryption,
+	// e.g. a connection.CaptureWriter to debug protocol issues. Changes apply to
+	// the next connection.
+	Recorder connection.Recorder
+
+	mutex   sync.RWMutex // guarding session, address and background
+	session *session     // of the current connection, nil if not connected
+	address string       // of the last server we connected to
+
+	background       context.Context // of work the client started on its own
+	cancelBackground context.CancelFunc
+
+	goroutines sync.WaitGroup // of all sessions
+	reconnects sync.WaitGroup // reconnectLoop
+
+	reconnectMutex   sync.Mutex // guarding the reconnect state
+	reconnectPolicy  *ReconnectPolicy
+	reconnectStop    chan struct{}
+	reconnecting     bool // whether reconnectLoop is running
+	reconnectAttempt int  // attempts since we were last logged on
 }
 
 type PacketHandler interface {
@@ -59,31 +104,61 @@ type PacketHandler interface {
 
 func NewClient() *Client {
 	client := &Client{
-		events:    make(chan interface{}, 3),
-		writeChan: make(chan IMsg, 5),
-		writeBuf:  new(bytes.Buffer),
+		events:     make(chan interface{}, 3),
+		eventsDone: make(chan struct{}),
+		bus:        new(eventBus),
+		jobs:       newJobManager(),
+		Router:     newRouter(),
 	}
+	// until Events() is called, nobody may be reading the channel
+	client.eventsSub = client.subscribe(reflect.TypeOf((*interface{})(nil)).Elem(), func(event interface{}) {
+		select {
+		case client.events <- event:
+		case <-client.eventsDone:
+		}
+	}, SubscriptionOptions{QueueSize: eventsBacklog, Overflow: OverflowDropOldest})
+	client.registerHandlers(client.Router)
 	client.Auth = &Auth{client: client}
-	client.RegisterPacketHandler(client.Auth)
+	client.Auth.registerHandlers(client.Router)
 	client.Social = newSocial(client)
-	client.RegisterPacketHandler(client.Social)
+	client.Social.registerHandlers(client.Router)
 	client.Web = &Web{client: client}
-	client.RegisterPacketHandler(client.Web)
+	client.Web.registerHandlers(client.Router)
 	client.Trading = &Trading{client: client}
-	client.RegisterPacketHandler(client.Trading)
+	client.Trading.registerHandlers(client.Router)
 	client.GC = newGC(client)
-	client.RegisterPacketHandler(client.GC)
+	client.GC.registerHandlers(client.Router)
+	client.Unified = newUnified(client)
+	client.Unified.registerHandlers(client.Router)
+	client.Authentication = newAuthentication(client, client.Unified)
+	client.AuthTickets = newAuthTickets(client)
+	client.AuthTickets.registerHandlers(client.Router)
+	client.Account = &Account{client: client}
+	client.Account.registerHandlers(client.Router)
+	client.GameServer = &GameServer{client: client}
+	client.GameServer.registerHandlers(client.Router)
 	return client
 }
 
 // Get the event channel. By convention all events are pointers, except for errors.
+//
+// Events emitted before the first call are kept, up to a limit. After that, no
+// event is dropped: the channel must be polled or the client stalls, including its
+// packet handlers and heartbeats, just like a subscription with OverflowBlock.
+// Use Subscribe with a drop policy instead if that's not acceptable.
+//
+// After Close, the channel receives no more events.
 func (c *Client) Events() <-chan interface{} {
+	c.eventsOnce.Do(func() {
+		c.eventsSub.setOverflow(OverflowBlock)
+	})
 	return c.events
 }
 
+// Queues the event for all interested subscribers and the Events() channel.
 func (c *Client) Emit(event interface{}) {
 	//fmt.Printf("%v\n", reflect.TypeOf(event))
-	c.events <- event
+	c.bus.publish(event)
 }
 
 // When this error is emitted by the Client, the connection is automatically closed.
@@ -92,7 +167,13 @@ type FatalError error
 
 // Emits a FatalError formatted with fmt.Errorf and disconnects.
 func (c *Client) Fatalf(format string, a ...interface{}) {
-	c.Emit(FatalError(fmt.Errorf(format, a...)))
+	c.fatal(fmt.Errorf(format, a...))
+}
+
+// Emits the error as FatalError and disconnects.
+func (c *Client) fatal(err error) {
+	c.finishConnect(err)
+	c.Emit(FatalError(err))
 	c.Disconnect()
 }
 
@@ -102,8 +183,9 @@ func (c *Client) Errorf(format string, a ...interface{}) {
 }
 
 // Registers a PacketHandler that receives all incoming packets.
-func (c *Client) RegisterPacketHandler(handler PacketHandler) {
-	c.handlers = append(c.handlers, handler)
+// Prefer registering handlers for specific EMsgs with the Router.
+func (c *Client) RegisterPacketHandler(handler PacketHandler) *HandlerToken {
+	return c.Router.HandleAll(handler.HandlePacket)
 }
 
 func (c *Client) GetNextJobId() JobId {
@@ -119,15 +201,31 @@ func (c *Client) SessionId() int32 {
 }
 
 func (c *Client) Connected() bool {
+	return c.currentSession() != nil
+}
+
+func (c *Client) currentSession() *session {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.conn != nil
+	return c.session
 }
 
-// Connects to a random server of the Steam network and returns the server.
+func (c *Client) isCurrent(s *session) bool {
+	return c.currentSession() == s
+}
+
+func (c *Client) currentConnection() connection.Connection {
+	s := c.currentSession()
+	if s == nil {
+		return nil
+	}
+	return s.conn
+}
+
+// Connects to the healthiest known server of the Steam network and returns the server.
 // If this client is already connected, it is disconnected first.
 func (c *Client) Connect() string {
-	server := servers.GetRandomCM()
+	server := c.serverList().Best()
 	c.ConnectTo(server)
 	return server
 }
@@ -148,129 +246,345 @@ func (c *Client) ConnectEurope() string {
 
 // Connects to a specific server.
 // If this client is already connected, it is disconnected first.
+//
+// The address may be prefixed with a scheme to select the transport, e.g.
+// "udp://72.165.61.174:27017" or "wss://cm.example.com:443/cmsocket/".
+// Without a scheme TCP is used.
+//
+// This returns as soon as the connection is open; wait for the ConnectedEvent
+// before logging on. If the server can't be reached, a FatalError is emitted.
 func (c *Client) ConnectTo(address string) {
-	c.Disconnect()
+	ctx := context.Background()
+	if c.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectionTimeout)
+		defer cancel()
+	}
+	_, err := c.dial(ctx, address)
+	if err != nil {
+		c.Emit(FatalError(err))
+	}
+}
 
-	conn, err := connection.DialTCP(address)
+var ErrConnectAborted = errors.New("Connection was closed before it was established")
+
+// Connects to a specific server like ConnectTo, but waits until the connection is
+// ready for logging on, i.e. until the ConnectedEvent is emitted, and returns
+// any error instead of emitting it.
+//
+// The context applies to dialing as well as to the encryption handshake. If it is
+// done first, the connection is closed and the context's error is returned.
+func (c *Client) ConnectContext(ctx context.Context, address string) error {
+	if c.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectionTimeout)
+		defer cancel()
+	}
+	s, err := c.dial(ctx, address)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	c.conn = conn
+	return c.waitConnected(ctx, s)
+}
 
-	go c.readLoop()
-	go c.writeLoop()
+// Connects over an established connection, e.g. one replaying a capture with
+// connection.NewReplayConnection, and waits like ConnectContext. If this client
+// is already connected, it is disconnected first.
+//
+// The client doesn't reconnect when such a connection is lost.
+func (c *Client) ConnectWith(ctx context.Context, conn connection.Connection) error {
+	if c.ConnectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ConnectionTimeout)
+		defer cancel()
+	}
+	c.endSession(nil)
+	return c.waitConnected(ctx, c.start(conn, "", time.Now()))
 }
 
-func (c *Client) Disconnect() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// Waits until the encryption handshake of the session finished or ctx is done.
+func (c *Client) waitConnected(ctx context.Context, s *session) error {
+	select {
+	case err := <-s.connectResult:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			s.finishConnect(ctx.Err())
+		} else {
+			s.abortConnect()
+		}
+		// only the first result counts, and the handshake may have won the race
+		if err := <-s.connectResult; err == nil {
+			return nil
+		}
+		c.endSession(s)
+		return ctx.Err()
+	}
+}
 
-	if c.conn == nil {
-		return
+// Opens a new connection and starts a session for it.
+func (c *Client) dial(ctx context.Context, address string) (*session, error) {
+	c.endSession(nil)
+
+	start := time.Now()
+	conn, err := connection.DialContext(ctx, c.Dialer, address)
+	if err != nil {
+		if err != context.Canceled {
+			c.reportServer(address, err, 0)
+		}
+		return nil, err
 	}
+	return c.start(conn, address, start), nil
+}
 
-	c.conn.Close()
-	c.conn = nil
-	if c.heartbeat != nil {
-		c.heartbeat.Stop()
-		c.heartbeat = nil
+// Starts a session for the connection, replacing the current one. The address is
+// empty for connections passed to ConnectWith.
+func (c *Client) start(conn connection.Connection, address string, start time.Time) *session {
+	s := newSession(c, conn, address, start)
+	c.mutex.Lock()
+	// somebody else may have connected while we were dialing
+	old := c.detach()
+	c.session = s
+	c.address = address
+	c.mutex.Unlock()
+	if old != nil {
+		old.conn.Close()
+		c.disconnected()
 	}
-	close(c.writeChan)
-	c.Emit(DisconnectedEvent{})
+	s.run()
+
+	// secure channels (WebSocket over TLS) skip the ChannelEncrypt handshake
+	if conn.IsEncrypted() {
+		s.setSecure()
+		s.finishConnect(nil)
+		c.Emit(ConnectedEvent{})
+	}
+	return s
 }
 
-// Adds a message to the send queue. Modifications to the given message after
-// writing are not allowed (possible race conditions).
-//
-// Writes to this client when not connected are ignored.
-func (c *Client) Write(msg IMsg) {
-	if cm, ok := msg.(IClientMsg); ok {
-		cm.SetSessionId(c.SessionId())
-		cm.SetSteamId(c.SteamId())
+// Reports the result of the encryption handshake of the current session.
+func (c *Client) finishConnect(err error) {
+	if s := c.currentSession(); s != nil {
+		s.finishConnect(err)
 	}
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	if c.conn == nil {
-		return
+}
+
+func (c *Client) serverList() *servers.ServerList {
+	if c.Servers != nil {
+		return c.Servers
 	}
-	c.writeChan <- msg
+	return servers.Default()
 }
 
-func (c *Client) readLoop() {
-	for {
-		c.mutex.RLock()
-		packet, err := c.conn.Read()
-		c.mutex.RUnlock()
-		if err != nil {
-			c.Fatalf("Error reading from the connection: %v", err)
+// Records the outcome of a connection attempt in the server list.
+// Servers reached over other transports than TCP and UDP are not tracked.
+func (c *Client) reportServer(address string, err error, latency time.Duration) {
+	if address == "" {
+		return
+	}
+	if i := strings.Index(address, "://"); i >= 0 {
+		if scheme := address[:i]; scheme != "tcp" && scheme != "udp" {
 			return
 		}
-		c.handlePacket(packet)
+		address = address[i+3:]
+	}
+	if err != nil {
+		c.serverList().ReportFailure(address)
+	} else {
+		c.serverList().ReportSuccess(address, latency)
+	}
+}
+
+// Closes the connection immediately. Messages that are still queued are dropped;
+// use Close to send them first. A pending logon retry, e.g. with a Steam Guard
+// code, is aborted.
+func (c *Client) Disconnect() {
+	c.stopBackground()
+	c.endSession(nil)
+}
+
+// Returns a context for work the client does on its own, like retrying a logon.
+// It is canceled by Disconnect and Close.
+func (c *Client) backgroundContext() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.background == nil {
+		c.background, c.cancelBackground = context.WithCancel(context.Background())
+	}
+	return c.background
+}
+
+func (c *Client) stopBackground() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cancelBackground != nil {
+		c.cancelBackground()
+		c.background, c.cancelBackground = nil, nil
+	}
+}
+
+// Closes the connection of the session, or of the current one if s is nil,
+// unless the session already ended.
+func (c *Client) endSession(s *session) {
+	c.mutex.Lock()
+	if s == nil {
+		s = c.session
+	}
+	if s == nil || s != c.session {
+		c.mutex.Unlock()
+		return
 	}
+	c.detach()
+	c.mutex.Unlock()
+
+	s.conn.Close()
+	c.disconnected()
 }
 
-func (c *Client) writeLoop() {
-	for {
-		c.mutex.RLock()
-		if c.conn == nil {
-			c.mutex.RUnlock()
+// Handles the first error of the session's connection. Reconnects if enabled and
+// emits a FatalError otherwise.
+func (c *Client) connectionFailed(s *session, err error) {
+	s.failOnce.Do(func() {
+		if !c.isCurrent(s) {
+			// errors after a disconnect are expected
 			return
 		}
-		msg, ok := <-c.writeChan
-		c.mutex.RUnlock()
-		if !ok {
-			return
+		if c.Auth.loggingOff() {
+			// Steam closes the connection after logging us off
+			c.endSession(s)
+		} else if !c.connectionLost(s, err) {
+			s.finishConnect(err)
+			c.Emit(FatalError(err))
+			c.endSession(s)
 		}
+	})
+}
 
-		err := msg.Serialize(c.writeBuf)
-		if err != nil {
-			c.writeBuf.Reset()
-			c.Errorf("Error serializing message %v: %v", msg, err)
-			return
+// Disables reconnecting, sends the queued messages and closes the connection.
+// Then waits until all goroutines of the client stopped, including those of the
+// previous connections. If ctx is done first, the connection is closed right away
+// and the context's error is returned. Unsaved changes of the server list are
+// saved on a best-effort basis.
+//
+// To end the session on the server as well, call Auth.LogOff first. Close must not
+// be called from packet handlers, and events must still be received while it waits.
+// Afterwards, the Events() channel is no longer fed and events not received by then
+// are discarded.
+func (c *Client) Close(ctx context.Context) error {
+	c.stopBackground()
+	c.DisableReconnect()
+	err := waitContext(ctx, &c.reconnects)
+
+	c.mutex.Lock()
+	s := c.detach()
+	c.mutex.Unlock()
+	if s != nil {
+		if err == nil {
+			select {
+			case <-s.writeDone:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
 		}
+		s.conn.Close()
+		c.disconnected()
+	}
+	c.serverList().Flush()
 
-		c.mutex.RLock()
-		err = c.conn.Write(c.writeBuf.Bytes())
-		c.mutex.RLock()
+	if err == nil {
+		err = waitContext(ctx, &c.goroutines)
+	}
+	c.closeOnce.Do(func() {
+		close(c.eventsDone)
+		c.eventsSub.Unsubscribe()
+	})
+	return err
+}
 
-		c.writeBuf.Reset()
+// Ends the current session without closing its connection and returns it, or nil
+// if there is none. The mutex must be held.
+func (c *Client) detach() *session {
+	s := c.session
+	if s == nil {
+		return nil
+	}
+	c.session = nil
+	s.close()
+	c.jobs.failAll(ErrJobAborted)
+	s.abortConnect()
+	return s
+}
 
-		if err != nil {
-			c.writeBuf.Reset()
-			c.Errorf("Error writing message %v: %v", msg, err)
-			return
-		}
+// Called after the connection was closed.
+func (c *Client) disconnected() {
+	c.Auth.finishLogOff()
+	c.AuthTickets.reset()
+	c.Emit(DisconnectedEvent{})
+}
+
+// Waits for the WaitGroup until ctx is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (c *Client) heartbeatLoop(seconds time.Duration) {
-	if c.heartbeat != nil {
-		c.heartbeat.Stop()
+// Adds a message to the send queue. Modifications to the given message after
+// writing are not allowed (possible race conditions).
+//
+// Writes to this client when not connected are ignored, including those before the
+// ConnectedEvent, which Steam would receive unencrypted.
+func (c *Client) Write(msg IMsg) {
+	c.write(msg)
+}
+
+// Writes like Write and returns false if the message was ignored.
+func (c *Client) write(msg IMsg) bool {
+	if s := c.currentSession(); s != nil && s.isSecure() {
+		return c.writeTo(s, msg)
 	}
-	c.heartbeat = time.NewTicker(seconds * time.Second)
-	for {
-		_, ok := <-c.heartbeat.C
-		if !ok {
-			break
-		}
-		c.Write(NewClientMsgProtobuf(EMsg_ClientHeartBeat, new(CMsgClientHeartBeat)))
+	return false
+}
+
+// Queues the message in the given session. Returns false if the session ended.
+func (c *Client) writeTo(s *session, msg IMsg) bool {
+	if cm, ok := msg.(IClientMsg); ok {
+		cm.SetSessionId(c.SessionId())
+		cm.SetSteamId(c.SteamId())
 	}
-	c.heartbeat = nil
+	return s.write(msg)
+}
+
+// Sends heartbeats in the given interval until the connection is closed,
+// replacing a running heartbeat.
+func (c *Client) startHeartbeat(interval time.Duration) {
+	if s := c.currentSession(); s != nil {
+		s.setHeartbeat(interval)
+	}
+}
+
+func (c *Client) registerHandlers(r *Router) {
+	r.Handle(EMsg_ChannelEncryptRequest, c.handleChannelEncryptRequest)
+	r.Handle(EMsg_ChannelEncryptResult, c.handleChannelEncryptResult)
+	r.Handle(EMsg_Multi, c.handleMulti)
+	r.Handle(EMsg_ClientCMList, c.handleCMList)
+	r.Handle(EMsg_ClientServerList, c.handleServerList)
 }
 
 func (c *Client) handlePacket(packet *PacketMsg) {
 	//fmt.Println(packet.EMsg)
-	switch packet.EMsg {
-	case EMsg_ChannelEncryptRequest:
-		c.handleChannelEncryptRequest(packet)
-	case EMsg_ChannelEncryptResult:
-		c.handleChannelEncryptResult(packet)
-	case EMsg_Multi:
-		c.handleMulti(packet)
-	}
-	for _, handler := range c.handlers {
-		handler.HandlePacket(packet)
+	if packet.TargetJobId != 0 && packet.TargetJobId != InvalidJobId {
+		c.jobs.handle(packet)
 	}
+	c.Router.Route(packet)
 }
 
 func (c *Client) handleChannelEncryptRequest(packet *PacketMsg) {
@@ -279,11 +593,19 @@ func (c *Client) handleChannelEncryptRequest(packet *PacketMsg) {
 
 	if body.Universe != EUniverse_Public {
 		c.Fatalf("Invalid univserse %v!", body.Universe)
+		return
 	}
 
-	c.tempSessionKey = make([]byte, 32)
-	rand.Read(c.tempSessionKey)
-	encryptedKey := cryptoutil.RSAEncrypt(keys.GetPublicKey(EUniverse_Public), c.tempSessionKey)
+	s := c.currentSession()
+	if s == nil {
+		return
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	s.mutex.Lock()
+	s.tempSessionKey = key
+	s.mutex.Unlock()
+	encryptedKey := cryptoutil.RSAEncrypt(keys.GetPublicKey(EUniverse_Public), key)
 
 	payload := new(bytes.Buffer)
 	payload.Write(encryptedKey)
@@ -293,7 +615,7 @@ func (c *Client) handleChannelEncryptRequest(packet *PacketMsg) {
 	payload.WriteByte(0)
 	payload.WriteByte(0)
 
-	c.Write(NewMsg(NewMsgChannelEncryptResponse(), payload.Bytes()))
+	c.writeTo(s, NewMsg(NewMsgChannelEncryptResponse(), payload.Bytes()))
 }
 
 func (c *Client) handleChannelEncryptResult(packet *PacketMsg) {
@@ -304,9 +626,20 @@ func (c *Client) handleChannelEncryptResult(packet *PacketMsg) {
 		c.Fatalf("Encryption failed: %v", body.Result)
 		return
 	}
-	c.conn.SetEncryptionKey(c.tempSessionKey)
-	c.tempSessionKey = nil
+	s := c.currentSession()
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	key := s.tempSessionKey
+	s.tempSessionKey = nil
+	s.mutex.Unlock()
+	if key != nil {
+		s.conn.SetEncryptionKey(key)
+	}
 
+	s.setSecure()
+	s.finishConnect(nil)
 	c.Emit(ConnectedEvent{})
 }
 
@@ -343,3 +676,36 @@ func (c *Client) handleMulti(packet *PacketMsg) {
 	}
 
 }
+
+func (c *Client) handleCMList(packet *PacketMsg) {
+	body := new(CMsgClientCMList)
+	packet.ReadProtoMsg(body)
+
+	ports := body.GetCmPorts()
+	var addresses []string
+	for i, ip := range body.GetCmAddresses() {
+		if i >= len(ports) {
+			break
+		}
+		addresses = append(addresses, serverAddress(ip, ports[i]))
+	}
+	c.serverList().Merge(addresses)
+}
+
+func (c *Client) handleServerList(packet *PacketMsg) {
+	body := new(CMsgClientServerList)
+	packet.ReadProtoMsg(body)
+
+	var addresses []string
+	for _, server := range body.GetServers() {
+		if EServerType(server.GetServerType()) == EServerType_CM {
+			addresses = append(addresses, serverAddress(server.GetServerIp(), server.GetServerPort()))
+		}
+	}
+	c.serverList().Merge(addresses)
+}
+
+// Formats an IPv4 address in host byte order and a port as "a.b.c.d:port".
+func serverAddress(ip, port uint32) string {
+	return net.JoinHostPort(net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)).String(), strconv.Itoa(int(port)))
+}
@@ -1,7 +1,24 @@
 package steamgo
 
-import ()
+import (
+	"time"
+)
 
 type ConnectedEvent struct{}
 
 type DisconnectedEvent struct{}
+
+// Emitted before every reconnect attempt if reconnecting is enabled.
+type ReconnectingEvent struct {
+	Attempt int
+	Server  string
+	Delay   time.Duration
+	// Why the last connection or attempt failed
+	Reason error
+}
+
+// Emitted when the client is connected, and logged on if it was before, again.
+type ReconnectedEvent struct {
+	Attempts int
+	Server   string
+}
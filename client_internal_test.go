@@ -0,0 +1,41 @@
+package steamgo
+
+import (
+	"context"
+	"github.com/gamingrobot/steamgo/servers"
+	"testing"
+	"time"
+)
+
+func TestWaitConnectedPrefersResult(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	// with both ready, select picks one at random
+	for i := 0; i < 100; i++ {
+		client := NewClient()
+		client.Servers, _ = servers.NewServerList(nil)
+		connectFake(client)
+		s := client.currentSession()
+		s.finishConnect(nil)
+		if err := client.waitConnected(ctx, s); err != nil {
+			t.Fatalf("Expected the finished handshake to win over the deadline, got %v", err)
+		}
+		if !client.isCurrent(s) {
+			t.Fatal("Expected the session to be kept")
+		}
+		client.Disconnect()
+	}
+
+	client := NewClient()
+	client.Servers, _ = servers.NewServerList(nil)
+	connectFake(client)
+	s := client.currentSession()
+	if err := client.waitConnected(ctx, s); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if client.isCurrent(s) {
+		t.Fatal("Expected the session to end")
+	}
+}
@@ -0,0 +1,163 @@
+package steamgo_test
+
+import (
+	"context"
+	"github.com/gamingrobot/steamgo"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/servers"
+	"github.com/gamingrobot/steamgo/testing/fakecm"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, server *fakecm.Server) *fakecm.Server {
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func newClient() *steamgo.Client {
+	client := steamgo.NewClient()
+	client.Servers, _ = servers.NewServerList(nil)
+	return client
+}
+
+// Connects a new client to the server and completes the encryption handshake.
+func connect(t *testing.T, server *fakecm.Server) *steamgo.Client {
+	client := newClient()
+	connectClient(t, client, server)
+	return client
+}
+
+func connectClient(t *testing.T, client *steamgo.Client, server *fakecm.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.ConnectContext(ctx, server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Logs on and waits for the LoggedOnEvent.
+func logOn(t *testing.T, client *steamgo.Client) {
+	loggedOn := make(chan struct{}, 1)
+	sub := client.Subscribe(func(steamgo.LoggedOnEvent) {
+		loggedOn <- struct{}{}
+	})
+	defer sub.Unsubscribe()
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	select {
+	case <-loggedOn:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the LoggedOnEvent")
+	}
+}
+
+// Waits until the client disconnected from the server.
+func waitDisconnected(t *testing.T, server *fakecm.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.WaitDisconnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func count(server *fakecm.Server, eMsg EMsg) int {
+	n := 0
+	for _, packet := range server.Received() {
+		if packet.EMsg == eMsg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLogOff(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	logOn(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Auth.LogOff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.Connected() {
+		t.Fatal("Expected the client to disconnect after logging off")
+	}
+	waitDisconnected(t, server)
+	if count(server, EMsg_ClientLogOff) != 1 {
+		t.Fatal("Expected the client to log off")
+	}
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogOffTimeout(t *testing.T) {
+	server := fakecm.NewServer()
+	server.Ignore = []EMsg{EMsg_ClientLogOff}
+	startServer(t, server)
+	defer server.Close()
+	client := connect(t, server)
+	logOn(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Auth.LogOff(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if !client.Connected() {
+		t.Fatal("Expected the client to stay connected until Steam logged us off")
+	}
+
+	server.DisconnectClients()
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseSendsQueuedMessages(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	logOn(t, client)
+
+	for i := 0; i < 20; i++ {
+		client.Write(NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitDisconnected(t, server)
+	if played := count(server, EMsg_ClientGamesPlayed); played != 20 {
+		t.Fatalf("Expected all 20 queued messages to be sent, got %v", played)
+	}
+
+	// writes after closing are ignored
+	client.Write(NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseWhileReconnecting(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	client.EnableReconnect(steamgo.ReconnectPolicy{MinDelay: time.Hour})
+	server.DisconnectClients()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.Connected() {
+		t.Fatal("Expected the client to stay disconnected")
+	}
+}
@@ -0,0 +1,137 @@
+package connection
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io"
+	"sync"
+	"time"
+)
+
+const captureMagic uint32 = 0x31434753 // "SGC1"
+
+type Direction uint8
+
+const (
+	Inbound  Direction = iota // sent by the CM
+	Outbound                  // sent by the client
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Inbound:
+		return "Inbound"
+	case Outbound:
+		return "Outbound"
+	}
+	return fmt.Sprintf("Direction(%d)", uint8(d))
+}
+
+// A packet as it was sent over a connection, before encryption.
+type CapturedPacket struct {
+	Time        time.Time
+	Direction   Direction
+	EMsg        EMsg
+	TargetJobId JobId
+	SourceJobId JobId
+	Data        []byte // the serialized message, including its header
+}
+
+// Receives every packet sent over a client's connections, e.g. a CaptureWriter.
+// Record may be called by several goroutines at once. The packet and its data
+// aren't modified after it was passed to Record.
+type Recorder interface {
+	Record(*CapturedPacket) error
+}
+
+// Writes packets to a capture file that can be read with CaptureReader or replayed
+// with NewReplayConnection.
+//
+// Captures contain everything in plain text, including passwords and session keys.
+type CaptureWriter struct {
+	mutex sync.Mutex
+	w     *bufio.Writer
+	err   error // the first error, returned by all later writes
+}
+
+// Writes the capture header to w.
+func NewCaptureWriter(w io.Writer) (*CaptureWriter, error) {
+	c := &CaptureWriter{w: bufio.NewWriter(w)}
+	binary.Write(c.w, binary.LittleEndian, captureMagic)
+	return c, c.w.Flush()
+}
+
+// Appends the packet to the capture. Every packet is flushed, so a capture is
+// complete up to the last packet even if the program crashes.
+func (c *CaptureWriter) Record(p *CapturedPacket) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	binary.Write(c.w, binary.LittleEndian, p.Time.UnixNano())
+	c.w.WriteByte(byte(p.Direction))
+	binary.Write(c.w, binary.LittleEndian, int32(p.EMsg))
+	binary.Write(c.w, binary.LittleEndian, uint64(p.TargetJobId))
+	binary.Write(c.w, binary.LittleEndian, uint64(p.SourceJobId))
+	binary.Write(c.w, binary.LittleEndian, uint32(len(p.Data)))
+	c.w.Write(p.Data)
+	c.err = c.w.Flush()
+	return c.err
+}
+
+// Reads the packets of a capture written by CaptureWriter.
+type CaptureReader struct {
+	r *bufio.Reader
+}
+
+// Reads the capture header from r.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	c := &CaptureReader{r: bufio.NewReader(r)}
+	var magic uint32
+	err := binary.Read(c.r, binary.LittleEndian, &magic)
+	if err != nil {
+		return nil, err
+	}
+	if magic != captureMagic {
+		return nil, fmt.Errorf("Invalid capture magic! Expected %d, got %d!", captureMagic, magic)
+	}
+	return c, nil
+}
+
+// Returns the next packet, or io.EOF at the end of the capture.
+func (c *CaptureReader) Read() (*CapturedPacket, error) {
+	var header struct {
+		Time        int64
+		Direction   Direction
+		EMsg        int32
+		TargetJobId uint64
+		SourceJobId uint64
+		Length      uint32
+	}
+	err := binary.Read(c.r, binary.LittleEndian, &header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	data := make([]byte, header.Length)
+	_, err = io.ReadFull(c.r, data)
+	if err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CapturedPacket{
+		Time:        time.Unix(0, header.Time),
+		Direction:   header.Direction,
+		EMsg:        EMsg(header.EMsg),
+		TargetJobId: JobId(header.TargetJobId),
+		SourceJobId: JobId(header.SourceJobId),
+		Data:        data,
+	}, nil
+}
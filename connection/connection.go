@@ -1,6 +1,7 @@
 package connection
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
@@ -9,6 +10,7 @@ import (
 	. "github.com/gamingrobot/steamgo/internal"
 	"io"
 	"net"
+	"strings"
 	"sync"
 )
 
@@ -17,23 +19,63 @@ type Connection interface {
 	Write([]byte) error
 	Close() error
 	SetEncryptionKey([]byte)
+	// Returns true if the channel is already secure. Connections that are
+	// encrypted from the start don't go through the ChannelEncrypt handshake.
 	IsEncrypted() bool
 }
 
+// Connects to the given address using dialer, or DefaultDialer if it is nil.
+// The transport is selected by the address' scheme: "udp://host:port" uses UDP,
+// "wss://host:port/cmsocket/" uses WebSocket and "tcp://host:port" or a plain
+// "host:port" uses TCP.
+func Dial(dialer Dialer, address string) (Connection, error) {
+	return DialContext(context.Background(), dialer, address)
+}
+
+// Like Dial, but gives up as soon as ctx is done. The context only applies to
+// establishing the connection, not to the returned Connection.
+func DialContext(ctx context.Context, dialer Dialer, address string) (Connection, error) {
+	switch {
+	case strings.HasPrefix(address, "ws://"):
+		return nil, errUnencryptedWebSocket(address)
+	case strings.HasPrefix(address, "wss://"):
+		conn, err := dialWebSocket(ctx, dialer, address, nil)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	case strings.HasPrefix(address, "udp://"):
+		conn, err := dialUDP(ctx, dialer, strings.TrimPrefix(address, "udp://"))
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("Unsupported connection scheme in %v", address)
+	}
+	conn, err := dialTCP(ctx, dialer, address)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 const tcpConnectionMagic uint32 = 0x31305456 // "VT01"
 
 type tcpConnection struct {
-	conn        *net.TCPConn
+	conn        net.Conn
 	ciph        cipher.Block
 	cipherMutex sync.RWMutex
 }
 
 func DialTCP(addr string) (*tcpConnection, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
-	if err != nil {
-		return nil, err
-	}
-	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	return dialTCP(context.Background(), nil, addr)
+}
+
+func dialTCP(ctx context.Context, dialer Dialer, addr string) (*tcpConnection, error) {
+	conn, err := dialContext(ctx, dialer, "tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +85,12 @@ func DialTCP(addr string) (*tcpConnection, error) {
 	}, nil
 }
 
+// Wraps an established TCP connection, e.g. one accepted by a fake CM in tests.
+// Both ends of a connection use the same framing.
+func NewTCPConnection(conn net.Conn) Connection {
+	return &tcpConnection{conn: conn}
+}
+
 func (c *tcpConnection) Read() (*PacketMsg, error) {
 	// All packets begin with a packet length
 	//fmt.Println(c)
@@ -73,10 +121,14 @@ func (c *tcpConnection) Read() (*PacketMsg, error) {
 
 	// Packets after ChannelEncryptResult are encrypted
 	c.cipherMutex.RLock()
-	if c.ciph != nil {
-		buf = cryptoutil.SymmetricDecrypt(c.ciph, buf)
-	}
+	ciph := c.ciph
 	c.cipherMutex.RUnlock()
+	if ciph != nil {
+		buf, err = cryptoutil.SymmetricDecryptChecked(ciph, buf)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return NewPacketMsg(buf)
 }
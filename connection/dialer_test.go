@@ -0,0 +1,242 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Listens on the loopback interface and echoes everything back.
+func newEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+// A SOCKS5 server requiring username/password authentication.
+func newSOCKS5Server(t *testing.T, username, password string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, username, password)
+		}
+	}()
+	return l
+}
+
+func serveSOCKS5(conn net.Conn, username, password string) {
+	defer conn.Close()
+	buf := make([]byte, 256)
+	io.ReadFull(conn, buf[:2])
+	io.ReadFull(conn, buf[:buf[1]])
+	conn.Write([]byte{5, socks5AuthPassword})
+
+	io.ReadFull(conn, buf[:2])
+	user := make([]byte, buf[1])
+	io.ReadFull(conn, user)
+	io.ReadFull(conn, buf[:1])
+	pass := make([]byte, buf[0])
+	io.ReadFull(conn, pass)
+	if string(user) != username || string(pass) != password {
+		conn.Write([]byte{1, 1})
+		return
+	}
+	conn.Write([]byte{1, 0})
+
+	io.ReadFull(conn, buf[:4])
+	var host string
+	switch buf[3] {
+	case socks5AddrIPv4:
+		io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case socks5AddrDomain:
+		io.ReadFull(conn, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// An HTTP proxy which only supports CONNECT and requires basic auth.
+func httpProxyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "CONNECT" {
+		http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+		return
+	}
+	if user, pass, ok := parseProxyAuth(r); !ok || user != "user" || pass != "pass" {
+		http.Error(w, "auth required", http.StatusProxyAuthRequired)
+		return
+	}
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer target.Close()
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+	rw.Flush()
+	go io.Copy(target, rw)
+	io.Copy(conn, target)
+}
+
+func parseProxyAuth(r *http.Request) (string, string, bool) {
+	r.Header.Set("Authorization", r.Header.Get("Proxy-Authorization"))
+	return r.BasicAuth()
+}
+
+func testDialEcho(t *testing.T, dialer Dialer, address string) {
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msg := []byte("Hello World!")
+	conn.Write(msg)
+	buf := make([]byte, len(msg))
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, msg) {
+		t.Fatalf("Expected %q, got %q", msg, buf)
+	}
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	proxy := newSOCKS5Server(t, "user", "pass")
+	defer proxy.Close()
+
+	dialer, err := NewProxyDialer("socks5://user:pass@"+proxy.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, dialer, echo.Addr().String())
+
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	testDialEcho(t, dialer, net.JoinHostPort("localhost", port))
+
+	bad := NewSOCKS5Dialer(proxy.Addr().String(), "user", "wrong", nil)
+	if _, err = bad.Dial("tcp", echo.Addr().String()); err == nil {
+		t.Fatal("Expected authentication to fail")
+	}
+	if _, err = dialer.Dial("udp", echo.Addr().String()); err == nil {
+		t.Fatal("Expected udp to be rejected")
+	}
+}
+
+func TestHTTPProxyDialer(t *testing.T) {
+	echo := newEchoServer(t)
+	defer echo.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(httpProxyHandler))
+	defer proxy.Close()
+	proxyAddress := strings.TrimPrefix(proxy.URL, "http://")
+
+	dialer, err := NewProxyDialer("http://user:pass@"+proxyAddress, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialEcho(t, dialer, echo.Addr().String())
+
+	bad := NewHTTPProxyDialer(proxyAddress, "", "", nil)
+	if _, err = bad.Dial("tcp", echo.Addr().String()); err == nil {
+		t.Fatal("Expected the proxy to require authentication")
+	}
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(httpProxyHandler))
+	defer proxy.Close()
+	server := httptest.NewTLSServer(http.HandlerFunc(webSocketEchoHandler))
+	defer server.Close()
+
+	dialer := NewHTTPProxyDialer(strings.TrimPrefix(proxy.URL, "http://"), "user", "pass", nil)
+	config := server.Client().Transport.(*http.Transport).TLSClientConfig
+	conn, err := DialWebSocket(dialer, "wss"+strings.TrimPrefix(server.URL, "https")+"/cmsocket/", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg := testMessage(100)
+	conn.Write(msg)
+	packet, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet.Data, msg) {
+		t.Fatal("Echoed message differs")
+	}
+}
+
+func TestDialContextTimeout(t *testing.T) {
+	// accepts connections but never answers the SOCKS5 greeting
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	dialer := NewSOCKS5Dialer(silent.Addr().String(), "", "", nil)
+	_, err = DialContext(ctx, dialer, "127.0.0.1:27017")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package connection
+
+import (
+	"errors"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io"
+	"sync"
+)
+
+var ErrReplayClosed = errors.New("replay: connection closed")
+
+// Maximum number of written messages waiting to be matched to the capture. The
+// oldest ones are discarded first.
+const replayMaxPending = 64
+
+// A connection that feeds the inbound packets of a capture to a client instead of
+// talking to a CM, so that a recorded session can be reproduced offline.
+//
+// An inbound packet is only delivered once the client sent the outbound messages
+// that preceded it in the capture, matched by their EMsg. Other messages are
+// discarded, at the latest once replayMaxPending newer ones were written. Heartbeats are ignored in both directions, as their number depends on
+// timing. Packets are delivered as fast as they are read, and Read returns io.EOF
+// at the end of the capture.
+type replayConnection struct {
+	reader    *CaptureReader
+	first     *CapturedPacket // read ahead to find out if the capture starts with the handshake
+	encrypted bool
+
+	mutex   sync.Mutex    // guarding sent and changed
+	sent    []EMsg        // written, but not matched to the capture yet
+	changed chan struct{} // closed and replaced whenever a message is written
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Creates a connection replaying the capture read from r, which must have been
+// written by a CaptureWriter. Captures of secure channels, which don't start
+// with the ChannelEncrypt handshake, are replayed as encrypted connections.
+//
+// Set the client's Recorder to a new CaptureWriter for every connection, as a
+// capture is always replayed as one connection.
+func NewReplayConnection(r io.Reader) (Connection, error) {
+	reader, err := NewCaptureReader(r)
+	if err != nil {
+		return nil, err
+	}
+	first, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &replayConnection{
+		reader:    reader,
+		first:     first,
+		encrypted: first == nil || first.Direction != Inbound || first.EMsg != EMsg_ChannelEncryptRequest,
+		changed:   make(chan struct{}),
+		closed:    make(chan struct{}),
+	}, nil
+}
+
+// Returns the next inbound packet of the capture, waiting until the client sent
+// the outbound messages in front of it.
+func (c *replayConnection) Read() (*PacketMsg, error) {
+	for {
+		select {
+		case <-c.closed:
+			return nil, ErrReplayClosed
+		default:
+		}
+		p, err := c.next()
+		if err != nil {
+			return nil, err
+		}
+		if p.Direction == Inbound {
+			return NewPacketMsg(p.Data)
+		}
+		if p.EMsg == EMsg_ClientHeartBeat {
+			continue
+		}
+		err = c.waitSent(p.EMsg)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Only used by Read.
+func (c *replayConnection) next() (*CapturedPacket, error) {
+	if p := c.first; p != nil {
+		c.first = nil
+		return p, nil
+	}
+	return c.reader.Read()
+}
+
+// Waits until the client sent a message of the given type.
+func (c *replayConnection) waitSent(eMsg EMsg) error {
+	for {
+		c.mutex.Lock()
+		for i, sent := range c.sent {
+			if sent == eMsg {
+				c.sent = append(c.sent[:i], c.sent[i+1:]...)
+				c.mutex.Unlock()
+				return nil
+			}
+		}
+		changed := c.changed
+		c.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-c.closed:
+			return ErrReplayClosed
+		}
+	}
+}
+
+func (c *replayConnection) Write(message []byte) error {
+	select {
+	case <-c.closed:
+		return ErrReplayClosed
+	default:
+	}
+	packet, err := NewPacketMsg(message)
+	if err != nil {
+		return err
+	}
+	if packet.EMsg == EMsg_ClientHeartBeat {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.sent) == replayMaxPending {
+		c.sent = append(c.sent[:0], c.sent[1:]...)
+	}
+	c.sent = append(c.sent, packet.EMsg)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return nil
+}
+
+func (c *replayConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+// The capture was recorded before encryption, so the key is ignored.
+func (c *replayConnection) SetEncryptionKey([]byte) {
+}
+
+func (c *replayConnection) IsEncrypted() bool {
+	return c.encrypted
+}
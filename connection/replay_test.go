@@ -0,0 +1,204 @@
+package connection
+
+import (
+	"bytes"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io"
+	"testing"
+	"time"
+)
+
+func serialize(t *testing.T, msg IMsg) []byte {
+	buf := new(bytes.Buffer)
+	if err := msg.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Writes a capture of the packets.
+func writeCapture(t *testing.T, packets ...*CapturedPacket) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w, err := NewCaptureWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		if err := w.Record(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf
+}
+
+func captured(t *testing.T, direction Direction, msg IMsg) *CapturedPacket {
+	return &CapturedPacket{
+		Time:        time.Unix(1500000000, 42),
+		Direction:   direction,
+		EMsg:        msg.GetMsgType(),
+		TargetJobId: msg.GetTargetJobId(),
+		SourceJobId: msg.GetSourceJobId(),
+		Data:        serialize(t, msg),
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	logOn := NewClientMsgProtobuf(EMsg_ClientLogon, new(CMsgClientLogon))
+	logOn.SetSourceJobId(7)
+	response := NewClientMsgProtobuf(EMsg_ClientLogOnResponse, new(CMsgClientLogonResponse))
+	response.SetTargetJobId(7)
+	packets := []*CapturedPacket{captured(t, Outbound, logOn), captured(t, Inbound, response)}
+
+	r, err := NewCaptureReader(writeCapture(t, packets...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range packets {
+		p, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Time.Equal(expected.Time) || p.Direction != expected.Direction || p.EMsg != expected.EMsg ||
+			p.TargetJobId != expected.TargetJobId || p.SourceJobId != expected.SourceJobId ||
+			!bytes.Equal(p.Data, expected.Data) {
+			t.Fatalf("Expected %+v, got %+v", expected, p)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("Expected EOF, got %v", err)
+	}
+}
+
+func TestCaptureInvalidMagic(t *testing.T) {
+	if _, err := NewCaptureReader(bytes.NewReader([]byte("VT01"))); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestReplayWaitsForClient(t *testing.T) {
+	logOn := NewClientMsgProtobuf(EMsg_ClientLogon, new(CMsgClientLogon))
+	response := NewClientMsgProtobuf(EMsg_ClientLogOnResponse, new(CMsgClientLogonResponse))
+	capture := writeCapture(t,
+		captured(t, Inbound, NewMsg(NewMsgChannelEncryptRequest(), nil)),
+		captured(t, Outbound, logOn),
+		captured(t, Inbound, response))
+
+	conn, err := NewReplayConnection(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.IsEncrypted() {
+		t.Fatal("Expected the handshake to be replayed")
+	}
+	packet, err := conn.Read()
+	if err != nil || packet.EMsg != EMsg_ChannelEncryptRequest {
+		t.Fatalf("Expected ChannelEncryptRequest, got %v, %v", packet, err)
+	}
+
+	packets := make(chan *PacketMsg)
+	go func() {
+		packet, err := conn.Read()
+		if err != nil {
+			t.Error(err)
+		}
+		packets <- packet
+	}()
+	// messages that aren't in the capture don't count
+	conn.Write(serialize(t, NewClientMsgProtobuf(EMsg_ClientHeartBeat, new(CMsgClientHeartBeat))))
+	select {
+	case packet := <-packets:
+		t.Fatalf("Received %v before the client logged on", packet)
+	case <-time.After(50 * time.Millisecond):
+	}
+	conn.Write(serialize(t, logOn))
+	select {
+	case packet := <-packets:
+		if packet == nil || packet.EMsg != EMsg_ClientLogOnResponse {
+			t.Fatalf("Expected ClientLogOnResponse, got %v", packet)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the response")
+	}
+
+	if _, err := conn.Read(); err != io.EOF {
+		t.Fatalf("Expected EOF, got %v", err)
+	}
+}
+
+func TestReplayClose(t *testing.T) {
+	capture := writeCapture(t, captured(t, Outbound, NewClientMsgProtobuf(EMsg_ClientLogon, new(CMsgClientLogon))))
+	conn, err := NewReplayConnection(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conn.IsEncrypted() {
+		t.Fatal("Expected captures without handshake to be encrypted")
+	}
+	errs := make(chan error)
+	go func() {
+		_, err := conn.Read()
+		errs <- err
+	}()
+	conn.Close()
+	select {
+	case err := <-errs:
+		if err != ErrReplayClosed {
+			t.Fatalf("Expected ErrReplayClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read blocked after Close")
+	}
+}
+
+func TestReplaySkipsHeartbeats(t *testing.T) {
+	heartbeat := NewClientMsgProtobuf(EMsg_ClientHeartBeat, new(CMsgClientHeartBeat))
+	response := NewClientMsgProtobuf(EMsg_ClientLogOnResponse, new(CMsgClientLogonResponse))
+	capture := writeCapture(t,
+		captured(t, Outbound, heartbeat),
+		captured(t, Outbound, heartbeat),
+		captured(t, Inbound, response))
+
+	conn, err := NewReplayConnection(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the client didn't send any heartbeats this time
+	packets := make(chan *PacketMsg, 1)
+	go func() {
+		packet, _ := conn.Read()
+		packets <- packet
+	}()
+	select {
+	case packet := <-packets:
+		if packet == nil || packet.EMsg != EMsg_ClientLogOnResponse {
+			t.Fatalf("Expected ClientLogOnResponse, got %v", packet)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The replay waited for the recorded heartbeats")
+	}
+}
+
+func TestReplayDiscardsUnmatchedMessages(t *testing.T) {
+	logOn := NewClientMsgProtobuf(EMsg_ClientLogon, new(CMsgClientLogon))
+	response := NewClientMsgProtobuf(EMsg_ClientLogOnResponse, new(CMsgClientLogonResponse))
+	conn, err := NewReplayConnection(writeCapture(t, captured(t, Outbound, logOn), captured(t, Inbound, response)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	unexpected := serialize(t, NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+	for i := 0; i < replayMaxPending*3; i++ {
+		conn.Write(unexpected)
+	}
+	if pending := len(conn.(*replayConnection).sent); pending != replayMaxPending {
+		t.Fatalf("Expected %v pending messages, got %v", replayMaxPending, pending)
+	}
+	conn.Write(serialize(t, logOn))
+	packet, err := conn.Read()
+	if err != nil || packet.EMsg != EMsg_ClientLogOnResponse {
+		t.Fatalf("Expected ClientLogOnResponse, got %v, %v", packet, err)
+	}
+}
@@ -0,0 +1,423 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"github.com/gamingrobot/steamgo/cryptoutil"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	udpHeaderSize  = 36
+	udpMaxPayload  = 0x4DC // maximum payload of a single datagram
+	udpLocalConnId = 512
+	// Packets further ahead of the last one processed in order are dropped.
+	udpReceiveWindow = 256
+)
+
+var (
+	// Unacknowledged packets are resent after this delay.
+	udpResendDelay = 3 * time.Second
+	// The connection is dropped if a packet stays unacknowledged for this long.
+	udpTimeout = 60 * time.Second
+	// Maximum time to wait for the challenge/connect handshake to complete.
+	udpConnectTimeout = 10 * time.Second
+)
+
+var ErrUDPHandshakeTimeout = errors.New("udp: timed out waiting for the server to accept the connection")
+
+type udpPacket struct {
+	header    *UdpHeader
+	payload   []byte
+	firstSent time.Time
+	lastSent  time.Time
+}
+
+func (p *udpPacket) serialize() []byte {
+	buf := new(bytes.Buffer)
+	p.header.PayloadSize = uint16(len(p.payload))
+	p.header.Serialize(buf)
+	buf.Write(p.payload)
+	return buf.Bytes()
+}
+
+// A connection using Steam's reliable UDP protocol ("VS01").
+// Messages are split into sequenced fragments which are acknowledged by the remote
+// side and resent if they get lost.
+type udpConnection struct {
+	conn        net.Conn
+	ciph        cipher.Block
+	cipherMutex sync.RWMutex
+
+	mutex        sync.Mutex // guarding everything below
+	remoteConnId uint32
+	connected    bool
+	outSeq       uint32 // sequence number of the next outgoing packet
+	inSeq        uint32 // highest incoming sequence number processed in order
+	outPackets   []*udpPacket
+	inPackets    map[uint32]*udpPacket
+	fragments    []*udpPacket
+	err          error
+	resendDelay  time.Duration
+	timeout      time.Duration
+
+	accepted  chan struct{}
+	messages  chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Connects to the given address using UDP and performs the challenge handshake.
+func DialUDP(addr string) (Connection, error) {
+	conn, err := dialUDP(context.Background(), nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// The dialer must support the "udp" network, which proxies usually don't.
+func dialUDP(ctx context.Context, dialer Dialer, addr string) (*udpConnection, error) {
+	conn, err := dialContext(ctx, dialer, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &udpConnection{
+		conn:        conn,
+		outSeq:      1,
+		inPackets:   make(map[uint32]*udpPacket),
+		resendDelay: udpResendDelay,
+		timeout:     udpTimeout,
+		accepted:    make(chan struct{}),
+		messages:    make(chan []byte, 64),
+		done:        make(chan struct{}),
+	}
+	go c.readLoop()
+	go c.resendLoop()
+
+	c.mutex.Lock()
+	c.sendSequenced(EUdpPacketType_ChallengeReq, nil, 1, 0, 0)
+	c.mutex.Unlock()
+
+	select {
+	case <-c.accepted:
+		return c, nil
+	case <-c.done:
+		return nil, c.error()
+	case <-time.After(udpConnectTimeout):
+		c.fail(ErrUDPHandshakeTimeout)
+		return nil, ErrUDPHandshakeTimeout
+	case <-ctx.Done():
+		c.fail(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+func (c *udpConnection) Read() (*PacketMsg, error) {
+	var buf []byte
+	select {
+	case buf = <-c.messages:
+	case <-c.done:
+		return nil, c.error()
+	}
+
+	// Packets after ChannelEncryptResult are encrypted
+	c.cipherMutex.RLock()
+	ciph := c.ciph
+	c.cipherMutex.RUnlock()
+	if ciph != nil {
+		var err error
+		buf, err = cryptoutil.SymmetricDecryptChecked(ciph, buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewPacketMsg(buf)
+}
+
+// Writes a message, splitting it into as many datagrams as needed.
+func (c *udpConnection) Write(message []byte) error {
+	c.cipherMutex.RLock()
+	if c.ciph != nil {
+		message = cryptoutil.SymmetricEncrypt(c.ciph, message)
+	}
+	c.cipherMutex.RUnlock()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	count := (len(message) + udpMaxPayload - 1) / udpMaxPayload
+	startSeq := c.outSeq
+	for i := 0; i < count; i++ {
+		end := (i + 1) * udpMaxPayload
+		if end > len(message) {
+			end = len(message)
+		}
+		err := c.sendSequenced(EUdpPacketType_Data, message[i*udpMaxPayload:end], uint32(count), startSeq, uint32(len(message)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sends a disconnect notification and closes the connection.
+func (c *udpConnection) Close() error {
+	c.mutex.Lock()
+	if c.err == nil && c.connected {
+		c.sendSequenced(EUdpPacketType_Disconnect, nil, 1, 0, 0)
+	}
+	c.mutex.Unlock()
+	c.fail(io.EOF)
+	return nil
+}
+
+func (c *udpConnection) SetEncryptionKey(key []byte) {
+	c.cipherMutex.Lock()
+	defer c.cipherMutex.Unlock()
+	if key == nil {
+		c.ciph = nil
+		return
+	}
+	if len(key) != 32 {
+		panic("Connection AES Key is not 32 bytes long!")
+	}
+
+	var err error
+	c.ciph, err = aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *udpConnection) IsEncrypted() bool {
+	c.cipherMutex.RLock()
+	defer c.cipherMutex.RUnlock()
+	return c.ciph != nil
+}
+
+func (c *udpConnection) error() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Closes the connection with the given error. Only the first error is kept.
+func (c *udpConnection) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mutex.Lock()
+		c.err = err
+		c.mutex.Unlock()
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Sends a sequenced packet and queues it for resending until it is acknowledged.
+// The mutex must be held.
+func (c *udpConnection) sendSequenced(packetType EUdpPacketType, payload []byte, packetsInMsg, msgStartSeq, msgSize uint32) error {
+	header := NewUdpHeader()
+	header.PacketType = packetType
+	header.SourceConnID = udpLocalConnId
+	header.DestConnID = c.remoteConnId
+	header.SeqThis = c.outSeq
+	header.SeqAck = c.inSeq
+	header.PacketsInMsg = packetsInMsg
+	header.MsgStartSeq = msgStartSeq
+	header.MsgSize = msgSize
+	if msgStartSeq == 0 {
+		header.MsgStartSeq = c.outSeq
+		header.MsgSize = uint32(len(payload))
+	}
+	c.outSeq++
+
+	now := time.Now()
+	packet := &udpPacket{header: header, payload: payload, firstSent: now, lastSent: now}
+	c.outPackets = append(c.outPackets, packet)
+	_, err := c.conn.Write(packet.serialize())
+	return err
+}
+
+// Acknowledges all packets received in order so far. The mutex must be held.
+func (c *udpConnection) sendAck() {
+	header := NewUdpHeader()
+	header.PacketType = EUdpPacketType_Datagram
+	header.SourceConnID = udpLocalConnId
+	header.DestConnID = c.remoteConnId
+	header.SeqAck = c.inSeq
+	packet := &udpPacket{header: header}
+	c.conn.Write(packet.serialize())
+}
+
+func (c *udpConnection) readLoop() {
+	buf := make([]byte, 2048)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if n < udpHeaderSize {
+			continue
+		}
+
+		r := bytes.NewReader(buf[:n])
+		header := NewUdpHeader()
+		if header.Deserialize(r) != nil || header.Magic != UdpHeader_MAGIC || int(header.PayloadSize) != r.Len() {
+			continue
+		}
+		payload := make([]byte, r.Len())
+		r.Read(payload)
+
+		msgs, err := c.receivePacket(&udpPacket{header: header, payload: payload})
+		for _, msg := range msgs {
+			select {
+			case c.messages <- msg:
+			case <-c.done:
+				return
+			}
+		}
+		if err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+// Handles an incoming packet and returns all messages it completed.
+func (c *udpConnection) receivePacket(packet *udpPacket) ([][]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	header := packet.header
+	if c.connected && header.SourceConnID != c.remoteConnId {
+		return nil, nil
+	}
+
+	// everything up to SeqAck has been received by the other side
+	for len(c.outPackets) > 0 && c.outPackets[0].header.SeqThis <= header.SeqAck {
+		c.outPackets = c.outPackets[1:]
+	}
+
+	if header.SeqThis == 0 {
+		// not sequenced, e.g. a plain ack
+		return nil, nil
+	}
+
+	// the other side resends everything that isn't acked, so it's fine to drop
+	// packets that would otherwise pile up
+	if header.SeqThis > c.inSeq && header.SeqThis-c.inSeq <= udpReceiveWindow {
+		c.inPackets[header.SeqThis] = packet
+	}
+	var msgs [][]byte
+	for {
+		next, ok := c.inPackets[c.inSeq+1]
+		if !ok {
+			break
+		}
+		delete(c.inPackets, c.inSeq+1)
+		c.inSeq++
+		msg, err := c.processPacket(next)
+		if err != nil {
+			return msgs, err
+		}
+		if msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	// always ack, the last one might have been lost
+	c.sendAck()
+	return msgs, nil
+}
+
+// Handles a sequenced packet in order and returns the message it completed, if any.
+// The mutex must be held.
+func (c *udpConnection) processPacket(packet *udpPacket) ([]byte, error) {
+	switch packet.header.PacketType {
+	case EUdpPacketType_Challenge:
+		challenge := NewChallengeData()
+		if err := challenge.Deserialize(bytes.NewReader(packet.payload)); err != nil {
+			return nil, err
+		}
+		data := NewConnectData()
+		data.ChallengeValue = challenge.ChallengeValue ^ ConnectData_CHALLENGE_MASK
+		buf := new(bytes.Buffer)
+		data.Serialize(buf)
+		return nil, c.sendSequenced(EUdpPacketType_Connect, buf.Bytes(), 1, 0, 0)
+	case EUdpPacketType_Accept:
+		if c.connected {
+			return nil, nil
+		}
+		c.connected = true
+		c.remoteConnId = packet.header.SourceConnID
+		close(c.accepted)
+	case EUdpPacketType_Data:
+		c.fragments = append(c.fragments, packet)
+		first := c.fragments[0].header
+		if uint32(len(c.fragments)) < first.PacketsInMsg {
+			return nil, nil
+		}
+		msg := make([]byte, 0, first.MsgSize)
+		for _, f := range c.fragments {
+			msg = append(msg, f.payload...)
+		}
+		c.fragments = nil
+		if uint32(len(msg)) != first.MsgSize {
+			return nil, fmt.Errorf("udp: invalid message size, expected %d, got %d", first.MsgSize, len(msg))
+		}
+		return msg, nil
+	case EUdpPacketType_Disconnect:
+		return nil, io.EOF
+	}
+	return nil, nil
+}
+
+func (c *udpConnection) resendLoop() {
+	ticker := time.NewTicker(c.resendDelay / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			if err := c.resend(now); err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// Resends all unacknowledged packets if the oldest one is overdue.
+func (c *udpConnection) resend(now time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.outPackets) == 0 || now.Sub(c.outPackets[0].lastSent) < c.resendDelay {
+		return nil
+	}
+	if now.Sub(c.outPackets[0].firstSent) > c.timeout {
+		return fmt.Errorf("udp: packet %d was not acknowledged in time", c.outPackets[0].header.SeqThis)
+	}
+	for _, packet := range c.outPackets {
+		packet.header.SeqAck = c.inSeq
+		packet.header.DestConnID = c.remoteConnId
+		packet.lastSent = now
+		if _, err := c.conn.Write(packet.serialize()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
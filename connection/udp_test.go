@@ -0,0 +1,262 @@
+package connection
+
+import (
+	"bytes"
+	. "github.com/gamingrobot/steamgo/internal"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A minimal server side of the UDP protocol listening on the loopback interface.
+// It answers the handshake and echoes every message back to the client.
+// Packets that arrive out of order are dropped, so the client has to resend them.
+type udpTestPeer struct {
+	conn   *net.UDPConn
+	remote *net.UDPAddr
+
+	mutex     sync.Mutex
+	outSeq    uint32
+	inSeq     uint32
+	fragments [][]byte
+	drop      int // number of data packets to drop
+	dropped   int
+}
+
+const udpTestChallenge = 0x12345678
+
+// Creates a new peer which drops the first drop data packets it receives.
+func newUDPTestPeer(t *testing.T, drop int) *udpTestPeer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &udpTestPeer{conn: conn, outSeq: 1, drop: drop}
+	go p.loop()
+	return p
+}
+
+func (p *udpTestPeer) Addr() string {
+	return p.conn.LocalAddr().String()
+}
+
+func (p *udpTestPeer) Close() {
+	p.conn.Close()
+}
+
+func (p *udpTestPeer) loop() {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		r := bytes.NewReader(buf[:n])
+		header := NewUdpHeader()
+		if header.Deserialize(r) != nil || header.Magic != UdpHeader_MAGIC {
+			continue
+		}
+		payload := make([]byte, r.Len())
+		r.Read(payload)
+
+		p.mutex.Lock()
+		p.remote = addr
+		p.handle(header, payload)
+		p.mutex.Unlock()
+	}
+}
+
+func (p *udpTestPeer) handle(header *UdpHeader, payload []byte) {
+	if header.SeqThis == 0 {
+		return
+	}
+	if header.SeqThis != p.inSeq+1 {
+		p.send(EUdpPacketType_Datagram, nil, 0, 0, 0, false)
+		return
+	}
+	if header.PacketType == EUdpPacketType_Data && p.dropped < p.drop {
+		p.dropped++
+		return
+	}
+	p.inSeq++
+
+	switch header.PacketType {
+	case EUdpPacketType_ChallengeReq:
+		buf := new(bytes.Buffer)
+		challenge := NewChallengeData()
+		challenge.ChallengeValue = udpTestChallenge
+		challenge.Serialize(buf)
+		p.send(EUdpPacketType_Challenge, buf.Bytes(), 1, p.outSeq, uint32(buf.Len()), true)
+	case EUdpPacketType_Connect:
+		data := NewConnectData()
+		data.Deserialize(bytes.NewReader(payload))
+		if data.ChallengeValue != udpTestChallenge^ConnectData_CHALLENGE_MASK {
+			return
+		}
+		p.send(EUdpPacketType_Accept, nil, 1, p.outSeq, 0, true)
+	case EUdpPacketType_Data:
+		p.fragments = append(p.fragments, payload)
+		if uint32(len(p.fragments)) < header.PacketsInMsg {
+			p.send(EUdpPacketType_Datagram, nil, 0, 0, 0, false)
+			return
+		}
+		msg := bytes.Join(p.fragments, nil)
+		p.fragments = nil
+		count := (len(msg) + udpMaxPayload - 1) / udpMaxPayload
+		start := p.outSeq
+		for i := 0; i < count; i++ {
+			end := (i + 1) * udpMaxPayload
+			if end > len(msg) {
+				end = len(msg)
+			}
+			p.send(EUdpPacketType_Data, msg[i*udpMaxPayload:end], uint32(count), start, uint32(len(msg)), true)
+		}
+	default:
+		p.send(EUdpPacketType_Datagram, nil, 0, 0, 0, false)
+	}
+}
+
+func (p *udpTestPeer) send(packetType EUdpPacketType, payload []byte, packetsInMsg, msgStartSeq, msgSize uint32, sequenced bool) {
+	header := NewUdpHeader()
+	header.PacketType = packetType
+	header.SourceConnID = 1
+	header.DestConnID = udpLocalConnId
+	header.SeqAck = p.inSeq
+	header.PacketsInMsg = packetsInMsg
+	header.MsgStartSeq = msgStartSeq
+	header.MsgSize = msgSize
+	if sequenced {
+		header.SeqThis = p.outSeq
+		p.outSeq++
+	}
+	packet := &udpPacket{header: header, payload: payload}
+	p.conn.WriteToUDP(packet.serialize(), p.remote)
+}
+
+// Returns a raw message with a valid header so it can be parsed by NewPacketMsg.
+func testMessage(size int) []byte {
+	buf := new(bytes.Buffer)
+	hdr := NewExtendedClientMsgHdr()
+	hdr.Msg = EMsg_ClientHeartBeat
+	hdr.Serialize(buf)
+	for buf.Len() < size {
+		buf.WriteByte(byte(buf.Len()))
+	}
+	return buf.Bytes()
+}
+
+func testEcho(t *testing.T, conn Connection, msg []byte) {
+	if err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	packet, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet.Data, msg) {
+		t.Fatalf("Echoed message differs, expected %v bytes, got %v bytes", len(msg), len(packet.Data))
+	}
+}
+
+func TestUDPHandshakeAndEcho(t *testing.T) {
+	peer := newUDPTestPeer(t, 0)
+	defer peer.Close()
+
+	conn, err := DialUDP(peer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	testEcho(t, conn, testMessage(100))
+	// spans multiple datagrams
+	testEcho(t, conn, testMessage(udpMaxPayload*3+17))
+}
+
+func TestUDPEncryptedEcho(t *testing.T) {
+	peer := newUDPTestPeer(t, 0)
+	defer peer.Close()
+
+	conn, err := DialUDP(peer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetEncryptionKey([]byte("0123456789abcdef0123456789abcdef"))
+	if !conn.IsEncrypted() {
+		t.Fatal("Connection should be encrypted")
+	}
+	testEcho(t, conn, testMessage(udpMaxPayload*2))
+}
+
+func TestUDPResend(t *testing.T) {
+	defer func(d time.Duration) { udpResendDelay = d }(udpResendDelay)
+	udpResendDelay = 100 * time.Millisecond
+
+	peer := newUDPTestPeer(t, 2)
+	defer peer.Close()
+
+	conn, err := DialUDP(peer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	testEcho(t, conn, testMessage(udpMaxPayload*2+1))
+}
+
+func TestUDPReceiveWindow(t *testing.T) {
+	// acks go nowhere
+	silent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	conn, err := net.Dial("udp", silent.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &udpConnection{conn: conn, inPackets: make(map[uint32]*udpPacket)}
+
+	for seq := uint32(2); seq <= udpReceiveWindow+10; seq++ {
+		header := NewUdpHeader()
+		header.PacketType = EUdpPacketType_Data
+		header.SeqThis = seq
+		if _, err := c.receivePacket(&udpPacket{header: header}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.inPackets) != udpReceiveWindow-1 {
+		t.Fatalf("Expected %v buffered packets, got %v", udpReceiveWindow-1, len(c.inPackets))
+	}
+	if _, ok := c.inPackets[udpReceiveWindow+1]; ok {
+		t.Fatal("Expected packets outside the window to be dropped")
+	}
+}
+
+func TestUDPHandshakeTimeout(t *testing.T) {
+	defer func(d time.Duration) { udpConnectTimeout = d }(udpConnectTimeout)
+	udpConnectTimeout = 100 * time.Millisecond
+
+	// nobody answers on this socket
+	silent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	_, err = DialUDP(silent.LocalAddr().String())
+	if err != ErrUDPHandshakeTimeout {
+		t.Fatalf("Expected handshake timeout, got %v", err)
+	}
+}
+
+func TestDialUnsupportedScheme(t *testing.T) {
+	_, err := Dial(nil, "gopher://127.0.0.1:1")
+	if err == nil {
+		t.Fatal("Expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,313 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	webSocketOpContinuation = 0x0
+	webSocketOpText         = 0x1
+	webSocketOpBinary       = 0x2
+	webSocketOpClose        = 0x8
+	webSocketOpPing         = 0x9
+	webSocketOpPong         = 0xA
+
+	webSocketMaxFrameSize = 1 << 26
+)
+
+var (
+	ErrWebSocketFrameTooLarge   = errors.New("websocket: frame too large")
+	ErrWebSocketMessageTooLarge = errors.New("websocket: message too large")
+)
+
+// The maximum size of a message assembled from continuation frames.
+var webSocketMaxMessageSize = webSocketMaxFrameSize
+
+// A connection to a CM over WebSocket. Every binary message carries exactly one
+// raw packet. Over "wss://", the channel is secured by TLS, so the ChannelEncrypt
+// handshake is skipped and IsEncrypted returns true. Connections over "ws://" aren't
+// encrypted at all; dialWebSocket only accepts them for tests.
+type webSocketConnection struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	writeMutex sync.Mutex
+	secure     bool // whether conn uses TLS
+}
+
+// Connects to the given "wss://" URL using dialer, or DefaultDialer if it is nil.
+// If config is nil, the default TLS configuration is used.
+func DialWebSocket(dialer Dialer, address string, config *tls.Config) (Connection, error) {
+	if strings.HasPrefix(address, "ws://") {
+		return nil, errUnencryptedWebSocket(address)
+	}
+	conn, err := dialWebSocket(context.Background(), dialer, address, config)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Without TLS, Steam would receive everything unencrypted.
+func errUnencryptedWebSocket(address string) error {
+	return fmt.Errorf("Unencrypted WebSocket connections are not supported, use wss:// instead of %v", address)
+}
+
+func dialWebSocket(ctx context.Context, dialer Dialer, address string, config *tls.Config) (*webSocketConnection, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "wss" && u.Scheme != "ws" {
+		return nil, fmt.Errorf("websocket: unsupported scheme %v", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	conn, err := dialContext(ctx, dialer, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "wss" {
+		if config == nil {
+			config = new(tls.Config)
+		}
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, config)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c := &webSocketConnection{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		secure: u.Scheme == "wss",
+	}
+	err = handshakeContext(ctx, conn, func() error {
+		return c.handshake(u)
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *webSocketConnection) handshake(u *url.URL) error {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	err = req.Write(c.conn)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.ReadResponse(c.reader, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("websocket: handshake failed with status %v", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return errors.New("websocket: invalid handshake response")
+	}
+	return nil
+}
+
+// Returns the expected Sec-WebSocket-Accept header for the given key.
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *webSocketConnection) Read() (*PacketMsg, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(c.reader)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case webSocketOpPing:
+			err = c.writeFrame(webSocketOpPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case webSocketOpPong:
+			continue
+		case webSocketOpClose:
+			c.writeFrame(webSocketOpClose, payload)
+			return nil, io.EOF
+		case webSocketOpBinary, webSocketOpText, webSocketOpContinuation:
+			if len(message)+len(payload) > webSocketMaxMessageSize {
+				return nil, ErrWebSocketMessageTooLarge
+			}
+			message = append(message, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+		}
+
+		if fin {
+			return NewPacketMsg(message)
+		}
+	}
+}
+
+// Writes a message as a single binary frame.
+func (c *webSocketConnection) Write(message []byte) error {
+	return c.writeFrame(webSocketOpBinary, message)
+}
+
+func (c *webSocketConnection) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return writeWebSocketFrame(c.conn, opcode, payload, true)
+}
+
+func (c *webSocketConnection) Close() error {
+	c.writeFrame(webSocketOpClose, nil)
+	return c.conn.Close()
+}
+
+// Encryption is provided by TLS, so the key is ignored.
+func (c *webSocketConnection) SetEncryptionKey([]byte) {
+}
+
+func (c *webSocketConnection) IsEncrypted() bool {
+	return c.secure
+}
+
+// Writes a single, final frame. Clients must mask their frames, servers must not.
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode // FIN
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+
+	length := len(payload)
+	switch {
+	case length < 126:
+		header[1] = maskBit | byte(length)
+	case length <= 0xFFFF:
+		header[1] = maskBit | 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = maskBit | 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if masked {
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		header = append(header, mask...)
+		maskedPayload := make([]byte, length)
+		for i := range payload {
+			maskedPayload[i] = payload[i] ^ mask[i%4]
+		}
+		payload = maskedPayload
+	}
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// Reads a single frame and unmasks its payload if necessary.
+func readWebSocketFrame(r io.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var l uint16
+		err = binary.Read(r, binary.BigEndian, &l)
+		length = uint64(l)
+	case 127:
+		err = binary.Read(r, binary.BigEndian, &length)
+	}
+	if err != nil {
+		return
+	}
+	if length > webSocketMaxFrameSize {
+		err = ErrWebSocketFrameTooLarge
+		return
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		_, err = io.ReadFull(r, mask)
+		if err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
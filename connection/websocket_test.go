@@ -0,0 +1,148 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Upgrades the request to a WebSocket and pings the client before echoing
+// every message back, split into two fragments.
+func webSocketEchoHandler(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "websocket required", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	rw.Flush()
+
+	for {
+		_, opcode, payload, err := readWebSocketFrame(rw)
+		if err != nil || opcode == webSocketOpClose {
+			return
+		}
+		if opcode != webSocketOpBinary {
+			continue
+		}
+		writeWebSocketFrame(conn, webSocketOpPing, []byte("ping"), false)
+
+		half := len(payload) / 2
+		first := new(bytes.Buffer)
+		writeWebSocketFrame(first, webSocketOpBinary, payload[:half], false)
+		frame := first.Bytes()
+		frame[0] &^= 0x80 // clear FIN
+		conn.Write(frame)
+		writeWebSocketFrame(conn, webSocketOpContinuation, payload[half:], false)
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(webSocketEchoHandler))
+	defer server.Close()
+
+	address := "wss" + strings.TrimPrefix(server.URL, "https") + "/cmsocket/"
+	config := server.Client().Transport.(*http.Transport).TLSClientConfig
+	conn, err := DialWebSocket(nil, address, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if !conn.IsEncrypted() {
+		t.Fatal("WebSocket connections should report as encrypted")
+	}
+
+	for _, size := range []int{50, 300, 70000} {
+		msg := testMessage(size)
+		if err := conn.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+		packet, err := conn.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(packet.Data, msg) {
+			t.Fatalf("Echoed message differs, expected %v bytes, got %v bytes", len(msg), len(packet.Data))
+		}
+	}
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := dialWebSocket(context.Background(), nil, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err == nil {
+		t.Fatal("Expected the handshake to fail")
+	}
+}
+
+func TestWebSocketFrameRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	payload := []byte("Hello World!")
+	if err := writeWebSocketFrame(buf, webSocketOpBinary, payload, true); err != nil {
+		t.Fatal(err)
+	}
+	fin, opcode, out, err := readWebSocketFrame(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fin || opcode != webSocketOpBinary || !bytes.Equal(out, payload) {
+		t.Fatalf("Invalid frame: fin %v, opcode %v, payload %q", fin, opcode, out)
+	}
+	if _, _, _, err = readWebSocketFrame(buf); err != io.EOF {
+		t.Fatalf("Expected EOF, got %v", err)
+	}
+}
+
+func TestWebSocketMessageTooLarge(t *testing.T) {
+	defer func(size int) {
+		webSocketMaxMessageSize = size
+	}(webSocketMaxMessageSize)
+	webSocketMaxMessageSize = 100
+
+	// fragments that are small enough on their own
+	buf := new(bytes.Buffer)
+	writeWebSocketFrame(buf, webSocketOpBinary, make([]byte, 60), false)
+	buf.Bytes()[0] &^= 0x80 // clear FIN
+	writeWebSocketFrame(buf, webSocketOpContinuation, make([]byte, 60), false)
+	conn := &webSocketConnection{reader: bufio.NewReader(buf)}
+	if _, err := conn.Read(); err != ErrWebSocketMessageTooLarge {
+		t.Fatalf("Expected ErrWebSocketMessageTooLarge, got %v", err)
+	}
+}
+
+func TestUnencryptedWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(webSocketEchoHandler))
+	defer server.Close()
+	address := "ws" + strings.TrimPrefix(server.URL, "http") + "/cmsocket/"
+
+	conn, err := dialWebSocket(context.Background(), nil, address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.IsEncrypted() {
+		t.Fatal("Expected ws:// connections to report as unencrypted")
+	}
+
+	if _, err := Dial(nil, address); err == nil {
+		t.Fatal("Expected Dial to reject ws://")
+	}
+	if _, err := DialWebSocket(nil, address, nil); err == nil {
+		t.Fatal("Expected DialWebSocket to reject ws://")
+	}
+}
@@ -0,0 +1,73 @@
+package steamgo
+
+import (
+	"github.com/gamingrobot/steamgo/vault"
+)
+
+// Keeps sentry files in the credentials of an AccountStore.
+type accountSentryStore struct {
+	store vault.AccountStore
+}
+
+func (s accountSentryStore) Load(username string) ([]byte, error) {
+	credentials, err := s.store.Load(username)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.Sentry, nil
+}
+
+func (s accountSentryStore) Save(username string, data []byte) error {
+	return vault.Update(s.store, username, func(c *vault.Credentials) {
+		c.Sentry = data
+	})
+}
+
+// Returns the SentryStore, or one backed by the AccountStore if it isn't set.
+func (a *Auth) sentryStore() SentryStore {
+	if a.SentryStore == nil && a.AccountStore != nil {
+		return accountSentryStore{a.AccountStore}
+	}
+	return a.SentryStore
+}
+
+// Returns the name of the account we log on with, or an empty string if we
+// don't log on to an account.
+func (a *Auth) accountName() string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.details == nil {
+		return ""
+	}
+	return a.details.Username
+}
+
+// Changes the stored credentials of the given account, if there is an AccountStore.
+func (a *Auth) updateCredentials(username string, fn func(*vault.Credentials)) {
+	if a.AccountStore == nil || username == "" {
+		return
+	}
+	err := vault.Update(a.AccountStore, username, fn)
+	if err != nil {
+		a.client.Errorf("Error saving the credentials of %v: %v", username, err)
+	}
+}
+
+// Fills in the stored refresh token or login key if the details contain neither
+// a password, a login key nor an access token.
+func (a *Auth) loadCredentials(details *LogOnDetails) {
+	if a.AccountStore == nil || details.Username == "" ||
+		details.Password != "" || details.LoginKey != "" || details.RefreshToken != "" {
+		return
+	}
+	credentials, err := a.AccountStore.Load(details.Username)
+	if err != nil {
+		a.client.Errorf("Error loading the credentials of %v: %v", details.Username, err)
+		return
+	}
+	if credentials.RefreshToken != "" {
+		details.RefreshToken = credentials.RefreshToken
+	} else {
+		details.LoginKey = credentials.LoginKey
+	}
+}
@@ -0,0 +1,129 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/vault"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logOnWithStore(t *testing.T, credentials *vault.Credentials) (*Client, *fakeConnection, *vault.MemoryStore, *CMsgClientLogon) {
+	client := NewClient()
+	store := vault.NewMemoryStore()
+	if credentials != nil {
+		store.Save("user", credentials)
+	}
+	client.Auth.AccountStore = store
+	conn := connectFake(client)
+
+	client.Auth.LogOn(LogOnDetails{Username: "user"})
+	body := new(CMsgClientLogon)
+	conn.expect(t, EMsg_ClientLogon).ReadProtoMsg(body)
+	return client, conn, store, body
+}
+
+func answerLogOn(t *testing.T, client *Client, result EResult) {
+	client.handlePacket(packetOf(t, NewClientMsgProtobuf(EMsg_ClientLogOnResponse, &CMsgClientLogonResponse{
+		Eresult: proto.Int32(int32(result)),
+	})))
+}
+
+func TestAccountStoreLoginKey(t *testing.T) {
+	client, conn, store, body := logOnWithStore(t, &vault.Credentials{LoginKey: "stored key"})
+	defer client.Disconnect()
+	if body.GetLoginKey() != "stored key" || body.Password != nil || !body.GetShouldRememberPassword() {
+		t.Fatalf("Expected a logon with the stored login key, got %v", body)
+	}
+
+	answerLogOn(t, client, EResult_OK)
+	client.handlePacket(packetOf(t, NewClientMsgProtobuf(EMsg_ClientNewLoginKey, &CMsgClientNewLoginKey{
+		UniqueId: proto.Uint32(1),
+		LoginKey: proto.String("new key"),
+	})))
+	conn.expect(t, EMsg_ClientNewLoginKeyAccepted)
+	if credentials, _ := store.Load("user"); credentials.LoginKey != "new key" {
+		t.Fatalf("Expected the new login key to be saved, got %+v", credentials)
+	}
+}
+
+func TestAccountStoreRefreshToken(t *testing.T) {
+	client, _, store, body := logOnWithStore(t, &vault.Credentials{LoginKey: "stored key", RefreshToken: "refresh"})
+	defer client.Disconnect()
+	if body.GetAccessToken() != "refresh" || body.LoginKey != nil || body.Password != nil {
+		t.Fatalf("Expected a logon with the stored refresh token, got %v", body)
+	}
+
+	// expired tokens are forgotten
+	answerLogOn(t, client, EResult_Expired)
+	if credentials, _ := store.Load("user"); credentials.RefreshToken != "" || credentials.LoginKey != "stored key" {
+		t.Fatalf("Expected only the refresh token to be removed, got %+v", credentials)
+	}
+}
+
+func TestAccountStoreRejectedLoginKey(t *testing.T) {
+	client, _, store, _ := logOnWithStore(t, &vault.Credentials{LoginKey: "stored key"})
+	defer client.Disconnect()
+
+	answerLogOn(t, client, EResult_InvalidPassword)
+	if credentials, _ := store.Load("user"); credentials.LoginKey != "" {
+		t.Fatalf("Expected the rejected login key to be removed, got %+v", credentials)
+	}
+}
+
+func TestAccountStoreWithoutCredentials(t *testing.T) {
+	client := NewClient()
+	client.Auth.AccountStore = vault.NewMemoryStore()
+	conn := connectFake(client)
+	errs := make(chan error, 1)
+	client.Subscribe(func(err FatalError) {
+		errs <- err
+	})
+
+	client.Auth.LogOn(LogOnDetails{Username: "user"})
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "No password or stored credentials for user") {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	case packet := <-conn.written:
+		t.Fatalf("Expected no logon, got %v", packet.EMsg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the FatalError")
+	}
+}
+
+func TestAccountStoreSentry(t *testing.T) {
+	client, conn, store, body := logOnWithStore(t, &vault.Credentials{LoginKey: "stored key"})
+	if body.ShaSentryfile != nil {
+		t.Fatalf("Expected no sentry yet, got %v", body)
+	}
+	updateMachineAuth(t, client, conn, 0, "sentry", 6)
+	if credentials, _ := store.Load("user"); string(credentials.Sentry) != "sentry" || credentials.LoginKey != "stored key" {
+		t.Fatalf("Expected the sentry to be saved with the credentials, got %+v", credentials)
+	}
+	client.Disconnect()
+
+	conn = connectFake(client)
+	defer client.Disconnect()
+	client.Auth.LogOn(LogOnDetails{Username: "user"})
+	body = new(CMsgClientLogon)
+	conn.expect(t, EMsg_ClientLogon).ReadProtoMsg(body)
+	if !bytes.Equal(body.ShaSentryfile, sentryHash([]byte("sentry"))) {
+		t.Fatalf("Expected the hash of the stored sentry, got %v", body)
+	}
+}
+
+func TestWebRestoresCookies(t *testing.T) {
+	client, _, _, _ := logOnWithStore(t, &vault.Credentials{LoginKey: "stored key", WebSessionId: "session", SteamLogin: "login"})
+	defer client.Disconnect()
+	if client.Web.SteamLogin != "" {
+		t.Fatal("Expected the cookies to be restored only after logging on")
+	}
+	answerLogOn(t, client, EResult_OK)
+	if client.Web.WebSessionId != "session" || client.Web.SteamLogin != "login" {
+		t.Fatalf("Expected the stored cookies, got %q and %q", client.Web.WebSessionId, client.Web.SteamLogin)
+	}
+}
@@ -0,0 +1,146 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"hash/crc32"
+	"net"
+	"time"
+)
+
+var (
+	ErrInvalidAppTicket  = errors.New("invalid app ticket")
+	ErrAppTicketChecksum = errors.New("app ticket checksum mismatch")
+)
+
+// The contents of an encrypted app ticket.
+type AppTicket struct {
+	Version    uint32
+	SteamId    SteamId
+	AppId      uint32
+	ExternalIp net.IP
+	InternalIp net.IP
+	Flags      uint32
+	Generated  time.Time
+	Expires    time.Time
+	Licenses   []uint32
+	Dlc        []AppTicketDlc
+	// The data passed when requesting the ticket
+	UserData []byte
+}
+
+type AppTicketDlc struct {
+	AppId    uint32
+	Licenses []uint32
+}
+
+// Decrypts and parses a serialized EncryptedAppTicket with the app's
+// encryption key from the Steamworks partner site. It returns ErrAppTicketChecksum
+// if the decrypted ticket doesn't match its CRC, which usually means the key is wrong.
+func DecryptEncryptedAppTicket(ticket []byte, key []byte) (*AppTicket, error) {
+	outer := new(EncryptedAppTicket)
+	if err := proto.Unmarshal(ticket, outer); err != nil {
+		return nil, err
+	}
+	ciph, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	// SymmetricDecryptChecked decrypts in place, and the ticket belongs to the caller
+	decrypted, err := SymmetricDecryptChecked(ciph, append([]byte(nil), outer.GetEncryptedTicket()...))
+	if err != nil {
+		return nil, ErrInvalidAppTicket
+	}
+	if crc32.ChecksumIEEE(decrypted) != outer.GetCrcEncryptedticket() {
+		return nil, ErrAppTicketChecksum
+	}
+
+	userDataLen := int(outer.GetCbEncrypteduserdata())
+	if userDataLen+4 > len(decrypted) {
+		return nil, ErrInvalidAppTicket
+	}
+	ownershipLen := int(binary.LittleEndian.Uint32(decrypted[userDataLen:]))
+	if ownershipLen > len(decrypted)-userDataLen {
+		return nil, ErrInvalidAppTicket
+	}
+	result, err := parseOwnershipTicket(decrypted[userDataLen : userDataLen+ownershipLen])
+	if err != nil {
+		return nil, err
+	}
+	result.UserData = decrypted[:userDataLen]
+	return result, nil
+}
+
+// Parses an app ownership ticket without signature.
+func parseOwnershipTicket(ticket []byte) (*AppTicket, error) {
+	r := &ticketReader{r: bytes.NewReader(ticket)}
+	r.uint32() // length
+	result := &AppTicket{
+		Version:    r.uint32(),
+		SteamId:    SteamId(r.uint64()),
+		AppId:      r.uint32(),
+		ExternalIp: r.ip(),
+		InternalIp: r.ip(),
+		Flags:      r.uint32(),
+		Generated:  time.Unix(int64(r.uint32()), 0),
+		Expires:    time.Unix(int64(r.uint32()), 0),
+	}
+	result.Licenses = r.licenses()
+	dlcCount := int(r.uint16())
+	for i := 0; i < dlcCount && r.err == nil; i++ {
+		result.Dlc = append(result.Dlc, AppTicketDlc{
+			AppId:    r.uint32(),
+			Licenses: r.licenses(),
+		})
+	}
+	if r.err != nil {
+		return nil, ErrInvalidAppTicket
+	}
+	return result, nil
+}
+
+// Reads little endian values and remembers the first error.
+type ticketReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (t *ticketReader) read(data interface{}) {
+	if t.err == nil {
+		t.err = binary.Read(t.r, binary.LittleEndian, data)
+	}
+}
+
+func (t *ticketReader) uint16() (v uint16) {
+	t.read(&v)
+	return
+}
+
+func (t *ticketReader) uint32() (v uint32) {
+	t.read(&v)
+	return
+}
+
+func (t *ticketReader) uint64() (v uint64) {
+	t.read(&v)
+	return
+}
+
+func (t *ticketReader) ip() net.IP {
+	v := t.uint32()
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (t *ticketReader) licenses() []uint32 {
+	count := int(t.uint16())
+	var licenses []uint32
+	for i := 0; i < count && t.err == nil; i++ {
+		licenses = append(licenses, t.uint32())
+	}
+	return licenses
+}
@@ -0,0 +1,85 @@
+package cryptoutil
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"crypto/aes"
+	"encoding/binary"
+	. "github.com/gamingrobot/steamgo/internal"
+	"hash/crc32"
+	"testing"
+)
+
+func buildEncryptedAppTicket(t *testing.T, key []byte, userData []byte) []byte {
+	ownership := new(bytes.Buffer)
+	for _, v := range []interface{}{
+		uint32(0), // length, set below
+		uint32(4),
+		uint64(76561197960287930),
+		uint32(440),
+		uint32(0x01020304),
+		uint32(0x0a000001),
+		uint32(0),
+		uint32(1400000000),
+		uint32(1400086400),
+		uint16(1), uint32(1000),
+		uint16(1), uint32(441), uint16(2), uint32(1001), uint32(1002),
+		uint16(0), // reserved
+	} {
+		binary.Write(ownership, binary.LittleEndian, v)
+	}
+	ownershipBytes := ownership.Bytes()
+	binary.LittleEndian.PutUint32(ownershipBytes, uint32(len(ownershipBytes)))
+
+	plain := append(append([]byte(nil), userData...), ownershipBytes...)
+	ciph, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := proto.Marshal(&EncryptedAppTicket{
+		TicketVersionNo:     proto.Uint32(1),
+		CrcEncryptedticket:  proto.Uint32(crc32.ChecksumIEEE(plain)),
+		CbEncrypteduserdata: proto.Uint32(uint32(len(userData))),
+		EncryptedTicket:     SymmetricEncrypt(ciph, plain),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ticket
+}
+
+func TestDecryptEncryptedAppTicket(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	ticket := buildEncryptedAppTicket(t, key, []byte("user data"))
+
+	decrypted, err := DecryptEncryptedAppTicket(ticket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.SteamId != 76561197960287930 || decrypted.AppId != 440 || decrypted.Version != 4 {
+		t.Fatalf("Wrong ticket header: %+v", decrypted)
+	}
+	if string(decrypted.UserData) != "user data" {
+		t.Fatalf("Wrong user data %q", decrypted.UserData)
+	}
+	if decrypted.ExternalIp.String() != "1.2.3.4" || decrypted.InternalIp.String() != "10.0.0.1" {
+		t.Fatalf("Wrong IPs %v, %v", decrypted.ExternalIp, decrypted.InternalIp)
+	}
+	if decrypted.Generated.Unix() != 1400000000 || decrypted.Expires.Unix() != 1400086400 {
+		t.Fatalf("Wrong times %v, %v", decrypted.Generated, decrypted.Expires)
+	}
+	if len(decrypted.Licenses) != 1 || decrypted.Licenses[0] != 1000 {
+		t.Fatalf("Wrong licenses %v", decrypted.Licenses)
+	}
+	if len(decrypted.Dlc) != 1 || decrypted.Dlc[0].AppId != 441 || len(decrypted.Dlc[0].Licenses) != 2 {
+		t.Fatalf("Wrong DLC %+v", decrypted.Dlc)
+	}
+}
+
+func TestDecryptEncryptedAppTicketWrongKey(t *testing.T) {
+	ticket := buildEncryptedAppTicket(t, []byte("0123456789abcdef0123456789abcdef"), nil)
+	_, err := DecryptEncryptedAppTicket(ticket, []byte("fedcba9876543210fedcba9876543210"))
+	if err != ErrAppTicketChecksum && err != ErrInvalidAppTicket {
+		t.Fatalf("Expected a checksum or invalid ticket error, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 )
 
 // Performs an encryption using AES/CBC/PKCS7
@@ -40,3 +41,29 @@ func SymmetricDecrypt(ciph cipher.Block, src []byte) []byte {
 	// remove the padding at the end
 	return unpadPKCS7(data)
 }
+
+var ErrInvalidCiphertext = errors.New("Invalid AES ciphertext")
+
+// Like SymmetricDecrypt, but returns ErrInvalidCiphertext instead of panicking or
+// returning garbage if src is too short or its padding is invalid, e.g. because it
+// wasn't encrypted with the key. It modifies the src slice as well.
+func SymmetricDecryptChecked(ciph cipher.Block, src []byte) ([]byte, error) {
+	if len(src) < 2*aes.BlockSize || len(src)%aes.BlockSize != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	iv := src[:aes.BlockSize]
+	newECBDecrypter(ciph).CryptBlocks(iv, iv)
+	data := src[aes.BlockSize:]
+	cipher.NewCBCDecrypter(ciph, iv).CryptBlocks(data, data)
+	// every byte of the padding is its length
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > aes.BlockSize {
+		return nil, ErrInvalidCiphertext
+	}
+	for _, b := range data[len(data)-padLen:] {
+		if int(b) != padLen {
+			return nil, ErrInvalidCiphertext
+		}
+	}
+	return data[:len(data)-padLen], nil
+}
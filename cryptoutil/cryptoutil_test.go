@@ -2,6 +2,7 @@ package cryptoutil
 
 import (
 	"crypto/aes"
+	"crypto/cipher"
 	"testing"
 )
 
@@ -21,3 +22,27 @@ func TestCrypt(t *testing.T) {
 		t.Fatalf("src length (%v) does not match decrypted length (%v)!", len([]byte("Hello World!")), len(decrypted))
 	}
 }
+
+func TestDecryptChecked(t *testing.T) {
+	ciph, err := aes.NewCipher([]byte("hunter2         "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := SymmetricDecryptChecked(ciph, SymmetricEncrypt(ciph, []byte("Hello World!")))
+	if err != nil || string(decrypted) != "Hello World!" {
+		t.Fatalf("Expected Hello World!, got %q, %v", decrypted, err)
+	}
+
+	// a valid last byte after invalid padding, which SymmetricDecrypt accepts
+	iv := make([]byte, aes.BlockSize)
+	block := []byte("Hello World!\x01\x02\x03\x02")
+	encrypted := make([]byte, 2*aes.BlockSize)
+	newECBEncrypter(ciph).CryptBlocks(encrypted[:aes.BlockSize], iv)
+	cipher.NewCBCEncrypter(ciph, iv).CryptBlocks(encrypted[aes.BlockSize:], block)
+
+	for _, src := range [][]byte{nil, []byte("plain text"), make([]byte, 3*aes.BlockSize), encrypted} {
+		if _, err := SymmetricDecryptChecked(ciph, src); err != ErrInvalidCiphertext {
+			t.Fatalf("Expected ErrInvalidCiphertext for %q, got %v", src, err)
+		}
+	}
+}
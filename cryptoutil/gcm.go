@@ -0,0 +1,72 @@
+package cryptoutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Derives a key of the given length from a passphrase using PBKDF2 with HMAC-SHA256.
+func DeriveKey(passphrase, salt []byte, iterations, keyLen int) []byte {
+	mac := hmac.New(sha256.New, passphrase)
+	key := make([]byte, 0, keyLen+mac.Size())
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block, i)
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write(block)
+		u := mac.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// Encrypts and authenticates src using AES-GCM with a random nonce prepended.
+// The key must be 16, 24 or 32 bytes long.
+func SealGCM(key, src []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, src, nil), nil
+}
+
+// Decrypts data encrypted by SealGCM. It fails if the key is wrong or the data
+// was modified.
+func OpenGCM(key, src []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(src) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, src[:gcm.NonceSize()], src[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	ciph, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(ciph)
+}
@@ -0,0 +1,38 @@
+package cryptoutil
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	for _, c := range []struct {
+		iterations, keyLen int
+		expected           string
+	}{
+		{1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{4096, 40, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134af7ad98c1b458ce3f"},
+	} {
+		key := hex.EncodeToString(DeriveKey([]byte("password"), []byte("salt"), c.iterations, c.keyLen))
+		if key != c.expected {
+			t.Errorf("Expected %v with %v iterations, got %v", c.expected, c.iterations, key)
+		}
+	}
+}
+
+func TestGCM(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sealed, err := SealGCM(key, []byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := OpenGCM(key, sealed)
+	if err != nil || string(opened) != "Hello World!" {
+		t.Fatalf("Expected the original text, got %q (%v)", opened, err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := OpenGCM(key, sealed); err == nil {
+		t.Fatal("Expected modified data to be rejected")
+	}
+}
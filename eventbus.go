@@ -0,0 +1,193 @@
+package steamgo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// What a subscription does with new events when its queue is full.
+type OverflowPolicy int
+
+const (
+	// Emit waits until the subscriber caught up. This stalls the client.
+	OverflowBlock OverflowPolicy = iota
+	// The oldest queued event is discarded.
+	OverflowDropOldest
+	// The new event is discarded.
+	OverflowDropNewest
+)
+
+type SubscriptionOptions struct {
+	// Number of events that may be queued for the subscriber
+	QueueSize int
+	Overflow  OverflowPolicy
+}
+
+var DefaultSubscriptionOptions = SubscriptionOptions{
+	QueueSize: 64,
+	Overflow:  OverflowBlock,
+}
+
+// Events emitted before the first call to Events() are kept up to this number.
+const eventsBacklog = 256
+
+// Delivers emitted events to the subscriptions interested in them.
+type eventBus struct {
+	mutex         sync.RWMutex
+	subscriptions []*Subscription // replaced, never modified
+}
+
+func (b *eventBus) add(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	subscriptions := make([]*Subscription, len(b.subscriptions), len(b.subscriptions)+1)
+	copy(subscriptions, b.subscriptions)
+	b.subscriptions = append(subscriptions, s)
+}
+
+func (b *eventBus) remove(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	subscriptions := make([]*Subscription, 0, len(b.subscriptions))
+	for _, other := range b.subscriptions {
+		if other != s {
+			subscriptions = append(subscriptions, other)
+		}
+	}
+	b.subscriptions = subscriptions
+}
+
+func (b *eventBus) publish(event interface{}) {
+	b.mutex.RLock()
+	subscriptions := b.subscriptions
+	b.mutex.RUnlock()
+	t := reflect.TypeOf(event)
+	for _, s := range subscriptions {
+		if t != nil && t.AssignableTo(s.eventType) {
+			s.push(event)
+		}
+	}
+}
+
+// A handler receiving events from its own queue and goroutine.
+type Subscription struct {
+	bus       *eventBus
+	eventType reflect.Type
+	handler   func(interface{})
+
+	mutex    sync.Mutex
+	cond     *sync.Cond // signalled when the queue or closed changes
+	queue    []interface{}
+	size     int
+	overflow OverflowPolicy
+	closed   bool
+	dropped  uint64
+}
+
+// Subscribes to events with DefaultSubscriptionOptions. The handler must be a
+// function taking a single event, e.g.
+//
+//	client.Subscribe(func(e steamgo.LoggedOnEvent) { ... })
+//
+// It receives all events assignable to the parameter type, so func(error) receives
+// all errors and func(interface{}) receives everything. Handlers are called one at a
+// time in the order the events were emitted, on a goroutine of their own.
+// It panics if the handler has a different signature.
+func (c *Client) Subscribe(handler interface{}) *Subscription {
+	return c.SubscribeWithOptions(handler, DefaultSubscriptionOptions)
+}
+
+// Subscribes to events like Subscribe with the given queue size and overflow policy.
+func (c *Client) SubscribeWithOptions(handler interface{}, options SubscriptionOptions) *Subscription {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		panic(fmt.Sprintf("steamgo: invalid event handler %v", t))
+	}
+	return c.subscribe(t.In(0), func(event interface{}) {
+		fn.Call([]reflect.Value{reflect.ValueOf(event)})
+	}, options)
+}
+
+func (c *Client) subscribe(eventType reflect.Type, handler func(interface{}), options SubscriptionOptions) *Subscription {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultSubscriptionOptions.QueueSize
+	}
+	s := &Subscription{
+		bus:       c.bus,
+		eventType: eventType,
+		handler:   handler,
+		size:      options.QueueSize,
+		overflow:  options.Overflow,
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	c.bus.add(s)
+	go s.loop()
+	return s
+}
+
+// Stops the subscription. Queued events are discarded; a handler that is
+// currently running finishes.
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+	s.mutex.Lock()
+	s.closed = true
+	s.queue = nil
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+// Returns the number of events discarded because the queue was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) setOverflow(overflow OverflowPolicy) {
+	s.mutex.Lock()
+	s.overflow = overflow
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+func (s *Subscription) push(event interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for !s.closed && len(s.queue) >= s.size {
+		switch s.overflow {
+		case OverflowDropOldest:
+			s.queue = s.queue[1:]
+			atomic.AddUint64(&s.dropped, 1)
+		case OverflowDropNewest:
+			atomic.AddUint64(&s.dropped, 1)
+			return
+		default:
+			s.cond.Wait()
+		}
+	}
+	if s.closed {
+		return
+	}
+	s.queue = append(s.queue, event)
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) loop() {
+	for {
+		s.mutex.Lock()
+		for !s.closed && len(s.queue) == 0 {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mutex.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+
+		s.handler(event)
+	}
+}
@@ -0,0 +1,223 @@
+package steamgo
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type testEvent int
+
+// A subscriber that blocks in its handler until released.
+type slowSubscriber struct {
+	sub      *Subscription
+	started  chan testEvent // receives every event when the handler starts
+	release  chan struct{}
+	received chan testEvent // receives every event when the handler returns
+}
+
+func newSlowSubscriber(client *Client, options SubscriptionOptions) *slowSubscriber {
+	s := &slowSubscriber{
+		started:  make(chan testEvent, 100),
+		release:  make(chan struct{}),
+		received: make(chan testEvent, 100),
+	}
+	s.sub = client.SubscribeWithOptions(func(e testEvent) {
+		s.started <- e
+		<-s.release
+		s.received <- e
+	}, options)
+	return s
+}
+
+// Emits the first event and waits until the handler blocks on it.
+func (s *slowSubscriber) block(t *testing.T, client *Client) {
+	client.Emit(testEvent(0))
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the handler")
+	}
+}
+
+// Releases the handler and returns the events it received until it was idle for a while.
+func (s *slowSubscriber) drain() []testEvent {
+	close(s.release)
+	var events []testEvent
+	for {
+		select {
+		case e := <-s.received:
+			events = append(events, e)
+		case <-time.After(100 * time.Millisecond):
+			return events
+		}
+	}
+}
+
+// Runs fn and returns a channel that is closed once it returned.
+func async(fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	return done
+}
+
+func expectBlocked(t *testing.T, done <-chan struct{}, what string) {
+	select {
+	case <-done:
+		t.Fatalf("Expected %v to block", what)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func expectDone(t *testing.T, done <-chan struct{}, what string) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %v", what)
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		overflow OverflowPolicy
+		received []testEvent
+		dropped  uint64
+	}{
+		{OverflowDropOldest, []testEvent{0, 3, 4}, 2},
+		{OverflowDropNewest, []testEvent{0, 1, 2}, 2},
+	}
+	for _, test := range tests {
+		client := NewClient()
+		s := newSlowSubscriber(client, SubscriptionOptions{QueueSize: 2, Overflow: test.overflow})
+		s.block(t, client)
+		// the handler holds 0, so the queue overflows after 2
+		for i := 1; i <= 4; i++ {
+			client.Emit(testEvent(i))
+		}
+		if dropped := s.sub.Dropped(); dropped != test.dropped {
+			t.Errorf("%v: Expected %v dropped events, got %v", test.overflow, test.dropped, dropped)
+		}
+		if received := s.drain(); !reflect.DeepEqual(received, test.received) {
+			t.Errorf("%v: Expected %v, got %v", test.overflow, test.received, received)
+		}
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	client := NewClient()
+	s := newSlowSubscriber(client, SubscriptionOptions{QueueSize: 2, Overflow: OverflowBlock})
+	s.block(t, client)
+	client.Emit(testEvent(1))
+	client.Emit(testEvent(2))
+
+	emitted := async(func() { client.Emit(testEvent(3)) })
+	expectBlocked(t, emitted, "Emit")
+	if received := s.drain(); !reflect.DeepEqual(received, []testEvent{0, 1, 2, 3}) {
+		t.Fatalf("Expected all events in order, got %v", received)
+	}
+	expectDone(t, emitted, "Emit")
+	if dropped := s.sub.Dropped(); dropped != 0 {
+		t.Fatalf("Expected no dropped events, got %v", dropped)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	client := NewClient()
+	events := make(chan testEvent, 10)
+	sub := client.Subscribe(func(e testEvent) {
+		events <- e
+	})
+	client.Emit(testEvent(1))
+	if e := <-events; e != 1 {
+		t.Fatalf("Expected 1, got %v", e)
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	client.Emit(testEvent(2))
+	select {
+	case e := <-events:
+		t.Fatalf("Received %v after unsubscribing", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribeDuringDelivery(t *testing.T) {
+	client := NewClient()
+	s := newSlowSubscriber(client, DefaultSubscriptionOptions)
+	s.block(t, client)
+	client.Emit(testEvent(1))
+
+	// doesn't wait for the running handler
+	expectDone(t, async(s.sub.Unsubscribe), "Unsubscribe")
+	client.Emit(testEvent(2))
+	if received := s.drain(); !reflect.DeepEqual(received, []testEvent{0}) {
+		t.Fatalf("Expected only the running handler to finish, got %v", received)
+	}
+}
+
+func TestUnsubscribeInHandler(t *testing.T) {
+	client := NewClient()
+	events := make(chan testEvent, 10)
+	var sub *Subscription
+	subscribed := make(chan struct{})
+	sub = client.Subscribe(func(e testEvent) {
+		<-subscribed
+		sub.Unsubscribe()
+		events <- e
+	})
+	close(subscribed)
+	client.Emit(testEvent(1))
+	client.Emit(testEvent(2))
+
+	if e := <-events; e != 1 {
+		t.Fatalf("Expected 1, got %v", e)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("Received %v after unsubscribing", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribeReleasesBlockedEmit(t *testing.T) {
+	client := NewClient()
+	s := newSlowSubscriber(client, SubscriptionOptions{QueueSize: 1, Overflow: OverflowBlock})
+	s.block(t, client)
+	client.Emit(testEvent(1))
+
+	emitted := async(func() { client.Emit(testEvent(2)) })
+	expectBlocked(t, emitted, "Emit")
+	s.sub.Unsubscribe()
+	expectDone(t, emitted, "Emit after unsubscribing")
+	if received := s.drain(); !reflect.DeepEqual(received, []testEvent{0}) {
+		t.Fatalf("Expected the queued events to be discarded, got %v", received)
+	}
+}
+
+func TestCloseStopsEvents(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		client := NewClient()
+		client.Events()
+		// more than the channel holds, so the feeding goroutine blocks
+		for j := 0; j < 10; j++ {
+			client.Emit(j)
+		}
+		if err := client.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the goroutines feeding Events() to stop, got %v instead of %v goroutines", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
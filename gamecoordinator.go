@@ -26,11 +26,11 @@ func (g *GameCoordinator) RegisterPacketHandler(handler GCPacketHandler) {
 	g.handlers = append(g.handlers, handler)
 }
 
-func (g *GameCoordinator) HandlePacket(packet *PacketMsg) {
-	if packet.EMsg != EMsg_ClientFromGC {
-		return
-	}
+func (g *GameCoordinator) registerHandlers(r *Router) {
+	r.Handle(EMsg_ClientFromGC, g.handleFromGC)
+}
 
+func (g *GameCoordinator) handleFromGC(packet *PacketMsg) {
 	msg := new(CMsgGCClient)
 	packet.ReadProtoMsg(msg)
 
@@ -0,0 +1,137 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"encoding/binary"
+	"errors"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"net"
+)
+
+// Registers a dedicated game server with Steam and authenticates its players.
+//
+// Log on with LogOn or Auth.LogOnAnonymousGameServer instead of Auth.LogOn. After the LoggedOnEvent,
+// report the server with SendServerType and keep the player list up to date. Steam
+// answers every SubmitTicket with a GSApproveEvent or a GSDenyEvent and may kick
+// players later with a GSKickEvent.
+type GameServer struct {
+	client *Client
+}
+
+type GameServerLogOnDetails struct {
+	// The game server login token. Anonymous game servers don't have one and log on
+	// with Auth.LogOnAnonymousGameServer instead.
+	Token string
+	AppId uint32
+}
+
+var ErrNoGameServerToken = errors.New("Game server login token must be set")
+
+// Logs on with a game server login token. Returns ErrNoGameServerToken without
+// logging on if there is none.
+func (g *GameServer) LogOn(details GameServerLogOnDetails) error {
+	if details.Token == "" {
+		return ErrNoGameServerToken
+	}
+	g.client.Auth.logOnGameServer(details)
+	return nil
+}
+
+// Describes the server to Steam.
+type GameServerInfo struct {
+	AppId     uint32
+	Flags     EServerFlags
+	Ip        net.IP
+	Port      uint16
+	QueryPort uint16
+	GameDir   string
+	Version   string
+}
+
+func (g *GameServer) SendServerType(info GameServerInfo) {
+	g.client.Write(NewClientMsgProtobuf(EMsg_GSServerType, &CMsgGSServerType{
+		AppIdServed:   proto.Uint32(info.AppId),
+		Flags:         proto.Uint32(uint32(info.Flags)),
+		GameIpAddress: proto.Uint32(ipToUint32(info.Ip)),
+		GamePort:      proto.Uint32(uint32(info.Port)),
+		GameDir:       proto.String(info.GameDir),
+		GameVersion:   proto.String(info.Version),
+		GameQueryPort: proto.Uint32(uint32(info.QueryPort)),
+	}))
+}
+
+type GameServerPlayer struct {
+	SteamId SteamId
+	Ip      net.IP
+	// The player's auth ticket
+	Token []byte
+}
+
+// Reports all players currently on the server.
+func (g *GameServer) SendPlayerList(players []GameServerPlayer) {
+	list := new(CMsgGSPlayerList)
+	for _, player := range players {
+		list.Players = append(list.Players, &CMsgGSPlayerList_Player{
+			SteamId:  proto.Uint64(uint64(player.SteamId)),
+			PublicIp: proto.Uint32(ipToUint32(player.Ip)),
+			Token:    player.Token,
+		})
+	}
+	g.client.Write(NewClientMsgProtobuf(EMsg_GSPlayerList, list))
+}
+
+// Submits the auth ticket of a connecting player. Steam answers with a
+// GSApproveEvent or a GSDenyEvent.
+func (g *GameServer) SubmitTicket(player GameServerPlayer) {
+	g.client.Write(NewClientMsgProtobuf(EMsg_GSUserPlaying, &CMsgGSUserPlaying{
+		SteamId:  proto.Uint64(uint64(player.SteamId)),
+		PublicIp: proto.Uint32(ipToUint32(player.Ip)),
+		Token:    player.Token,
+	}))
+}
+
+func (g *GameServer) registerHandlers(r *Router) {
+	r.HandleBody(EMsg_GSStatusReply, g.handleStatusReply)
+	r.HandleBody(EMsg_GSApprove, g.handleApprove)
+	r.HandleBody(EMsg_GSDeny, g.handleDeny)
+	r.HandleBody(EMsg_GSKick, g.handleKick)
+}
+
+func (g *GameServer) handleStatusReply(packet *PacketMsg, body *CMsgGSStatusReply) {
+	g.client.Emit(GSStatusReplyEvent{
+		IsSecure: body.GetIsSecure(),
+	})
+}
+
+func (g *GameServer) handleApprove(packet *PacketMsg, body *CMsgGSApprove) {
+	g.client.Emit(GSApproveEvent{
+		SteamId: SteamId(body.GetSteamId()),
+		Owner:   SteamId(body.GetOwnerSteamId()),
+	})
+}
+
+func (g *GameServer) handleDeny(packet *PacketMsg, body *CMsgGSDeny) {
+	g.client.Emit(GSDenyEvent{
+		SteamId: SteamId(body.GetSteamId()),
+		Reason:  EDenyReason(body.GetEdenyReason()),
+		Message: body.GetDenyString(),
+	})
+}
+
+func (g *GameServer) handleKick(packet *PacketMsg, body *CMsgGSKick) {
+	g.client.Emit(GSKickEvent{
+		SteamId: SteamId(body.GetSteamId()),
+		Reason:  EDenyReason(body.GetEdenyReason()),
+	})
+}
+
+// Converts an IPv4 address to host byte order as Steam expects it.
+// Returns 0 for nil and IPv6 addresses.
+func ipToUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	if ip == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ip)
+}
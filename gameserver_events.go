@@ -0,0 +1,31 @@
+package steamgo
+
+import (
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+)
+
+type GSStatusReplyEvent struct {
+	// Whether the server is VAC secured
+	IsSecure bool
+}
+
+// A player whose ticket was submitted may play.
+type GSApproveEvent struct {
+	SteamId SteamId `json:",string"`
+	// The owner of the game if it is borrowed through family sharing
+	Owner SteamId `json:",string"`
+}
+
+// A player whose ticket was submitted may not play.
+type GSDenyEvent struct {
+	SteamId SteamId `json:",string"`
+	Reason  EDenyReason
+	Message string
+}
+
+// A player must be removed from the server.
+type GSKickEvent struct {
+	SteamId SteamId `json:",string"`
+	Reason  EDenyReason
+}
@@ -0,0 +1,117 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"net"
+	"testing"
+	"time"
+)
+
+var playerId = NewIdAdv(2, 1, int32(EUniverse_Public), EAccountType_Individual)
+
+func TestGameServerLogOn(t *testing.T) {
+	tests := []struct {
+		logOn       func(*Client)
+		eMsg        EMsg
+		token       string
+		accountType int32
+	}{
+		{func(c *Client) { c.GameServer.LogOn(GameServerLogOnDetails{Token: "token", AppId: 440}) }, EMsg_ClientLogonGameServer, "token", EAccountType_GameServer},
+		// anonymous game servers don't use ClientLogonGameServer
+		{func(c *Client) { c.Auth.LogOnAnonymousGameServer(440) }, EMsg_ClientLogon, "", EAccountType_AnonGameServer},
+	}
+	for _, test := range tests {
+		client := NewClient()
+		conn := connectFake(client)
+		test.logOn(client)
+
+		body := new(CMsgClientLogon)
+		msg := conn.expect(t, test.eMsg).ReadProtoMsg(body)
+		if body.GetGameServerAppId() != 440 || body.GetGameServerToken() != test.token || body.AccountName != nil || body.Password != nil {
+			t.Errorf("Unexpected logon %v", body)
+		}
+		if accountType := SteamId(msg.Header.Proto.GetSteamid()).GetAccountType(); accountType != test.accountType {
+			t.Errorf("Expected account type %v, got %v", test.accountType, accountType)
+		}
+		client.Disconnect()
+	}
+
+	if err := NewClient().GameServer.LogOn(GameServerLogOnDetails{AppId: 440}); err != ErrNoGameServerToken {
+		t.Fatalf("Expected LogOn to require a token, got %v", err)
+	}
+}
+
+func TestGameServerTickets(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+
+	client.GameServer.SendServerType(GameServerInfo{
+		AppId: 440,
+		Ip:    net.IPv4(1, 2, 3, 4),
+		Port:  27015,
+	})
+	serverType := new(CMsgGSServerType)
+	conn.expect(t, EMsg_GSServerType).ReadProtoMsg(serverType)
+	if serverType.GetAppIdServed() != 440 || serverType.GetGameIpAddress() != 0x01020304 || serverType.GetGamePort() != 27015 {
+		t.Fatalf("Unexpected server type %v", serverType)
+	}
+
+	player := GameServerPlayer{
+		SteamId: playerId,
+		Ip:      net.IPv4(5, 6, 7, 8),
+		Token:   []byte("ticket"),
+	}
+	client.GameServer.SubmitTicket(player)
+	playing := new(CMsgGSUserPlaying)
+	conn.expect(t, EMsg_GSUserPlaying).ReadProtoMsg(playing)
+	if SteamId(playing.GetSteamId()) != playerId || playing.GetPublicIp() != 0x05060708 || string(playing.GetToken()) != "ticket" {
+		t.Fatalf("Unexpected ticket submission %v", playing)
+	}
+
+	client.GameServer.SendPlayerList([]GameServerPlayer{player, {SteamId: 1, Ip: net.ParseIP("::1")}})
+	list := new(CMsgGSPlayerList)
+	conn.expect(t, EMsg_GSPlayerList).ReadProtoMsg(list)
+	if len(list.GetPlayers()) != 2 || list.GetPlayers()[0].GetPublicIp() != 0x05060708 || list.GetPlayers()[1].GetPublicIp() != 0 {
+		t.Fatalf("Unexpected player list %v", list)
+	}
+}
+
+func TestGameServerVerdicts(t *testing.T) {
+	client := NewClient()
+	events := make(chan interface{}, 10)
+	client.Subscribe(func(event interface{}) {
+		events <- event
+	})
+	expect := func(expected interface{}) {
+		select {
+		case event := <-events:
+			if event != expected {
+				t.Fatalf("Expected %+v, got %+v", expected, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %T", expected)
+		}
+	}
+
+	client.handlePacket(packetOf(t, NewClientMsgProtobuf(EMsg_GSApprove, &CMsgGSApprove{
+		SteamId:      proto.Uint64(uint64(playerId)),
+		OwnerSteamId: proto.Uint64(uint64(playerId)),
+	})))
+	expect(GSApproveEvent{SteamId: playerId, Owner: playerId})
+
+	client.handlePacket(packetOf(t, NewClientMsgProtobuf(EMsg_GSDeny, &CMsgGSDeny{
+		SteamId:     proto.Uint64(uint64(playerId)),
+		EdenyReason: proto.Int32(int32(EDenyReason_NotLoggedOn)),
+		DenyString:  proto.String("Not logged on"),
+	})))
+	expect(GSDenyEvent{SteamId: playerId, Reason: EDenyReason_NotLoggedOn, Message: "Not logged on"})
+
+	client.handlePacket(packetOf(t, NewClientMsgProtobuf(EMsg_GSKick, &CMsgGSKick{
+		SteamId:     proto.Uint64(uint64(playerId)),
+		EdenyReason: proto.Int32(int32(EDenyReason_Cheater)),
+	})))
+	expect(GSKickEvent{SteamId: playerId, Reason: EDenyReason_Cheater})
+}
@@ -5,6 +5,7 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
@@ -26,12 +27,16 @@ func main() {
 		clean()
 		found = true
 	}
-	if strings.Contains(args, "steamlang") {
-		buildSteamLanguage(!strings.Contains(args, "steamlang:nodebug"))
-		found = true
-	}
-	if strings.Contains(args, "proto") {
-		buildProto()
+	steamlang, proto := strings.Contains(args, "steamlang"), strings.Contains(args, "proto")
+	if steamlang || proto {
+		steamKit := prepareSteamKit()
+		defer os.RemoveAll(steamKit)
+		if steamlang {
+			buildSteamLanguage(steamKit, !strings.Contains(args, "steamlang:nodebug"))
+		}
+		if proto {
+			buildProto(steamKit)
+		}
 		found = true
 	}
 
@@ -55,7 +60,113 @@ func clean() {
 	os.Remove("../internal/steam_language_internal.go")
 }
 
-func buildSteamLanguage(debug bool) {
+// Definitions newer than the SteamKit revision in ./SteamKit, by resource file and then enum or message.
+// They're appended to a copy of SteamKit's resources, so regenerating keeps them.
+var steamKitAdditions = map[string]map[string][]string{
+	"Protobufs/steamclient/steammessages_clientserver.proto": {
+		"CMsgClientLogon": {
+			"optional string two_factor_code = 101;",
+			"optional string access_token = 108;",
+		},
+	},
+	"SteamLanguage/enums.steamd": {
+		"EResult": {
+			"AccountLoginDeniedNeedTwoFactor = 85;",
+			"ItemDeleted = 86;",
+			"AccountLoginDeniedThrottle = 87;",
+			"TwoFactorCodeMismatch = 88;",
+		},
+	},
+	"SteamLanguage/emsg.steamd": {
+		"EMsg": {
+			"ServiceMethodCallFromClientNonAuthed = 9804;",
+		},
+	},
+}
+
+var definitionRegex = regexp.MustCompile("(\\w+)\\s*=\\s*-?\\d+")
+
+// Copies SteamKit's resources to a temporary SteamKit root with the additions applied and returns its path.
+func prepareSteamKit() string {
+	print("# Preparing SteamKit resources")
+	root, err := ioutil.TempDir("", "steamkit")
+	if err != nil {
+		panic(err)
+	}
+	for _, dir := range []string{"Protobufs", "SteamLanguage"} {
+		copyDir(filepath.Join("SteamKit/Resources", dir), filepath.Join(root, "Resources", dir))
+	}
+	// our own protobufs are compiled along with SteamKit's
+	copyDir("Protobufs", filepath.Join(root, "Resources", "Protobufs"))
+
+	for name, blocks := range steamKitAdditions {
+		path := filepath.Join(root, "Resources", name)
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		lines := strings.Split(string(file), "\n")
+		for block, definitions := range blocks {
+			lines = appendDefinitions(lines, block, definitions)
+		}
+		err = ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0666)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return root
+}
+
+// Appends the definitions missing from the enum or message to its end.
+func appendDefinitions(lines []string, block string, definitions []string) []string {
+	start := regexp.MustCompile("^\\s*(enum|message)\\s+" + block + "\\b")
+	first, depth := -1, 0
+	for i, line := range lines {
+		if first < 0 {
+			if !start.MatchString(line) {
+				continue
+			}
+			first = i
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth > 0 || !strings.Contains(line, "}") {
+			continue
+		}
+
+		body := strings.Join(lines[first:i], "\n")
+		var missing []string
+		for _, definition := range definitions {
+			name := definitionRegex.FindStringSubmatch(definition)[1]
+			if !regexp.MustCompile("\\b" + name + "\\s*=").MatchString(body) {
+				missing = append(missing, "\t"+definition)
+			}
+		}
+		return append(lines[:i], append(missing, lines[i:]...)...)
+	}
+	panic(fmt.Sprintf("%v not found in SteamKit", block))
+}
+
+func copyDir(src, dst string) {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0777)
+		}
+		file, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), file, 0666)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func buildSteamLanguage(steamKit string, debug bool) {
 	print("# Building Steam Language")
 	exePath := "./GoSteamLanguageGenerator/bin/Debug/GoSteamLanguageGenerator.exe"
 	d := ""
@@ -63,20 +174,20 @@ func buildSteamLanguage(debug bool) {
 		d = "debug"
 	}
 	if runtime.GOOS != "windows" {
-		execute("mono", exePath, "./SteamKit", "../internal", d)
+		execute("mono", exePath, steamKit, "../internal", d)
 	} else {
-		execute(exePath, "./SteamKit", "../internal", d)
+		execute(exePath, steamKit, "../internal", d)
 	}
 	execute("gofmt", "-w", "../internal/steam_language_enums.go", "../internal/steam_language_internal.go")
 }
 
 var pkgRegex = regexp.MustCompile("(package \\w+)")
 
-func buildProto() {
+func buildProto(steamKit string) {
 	print("# Building Proto")
 
 	print("## Compiling")
-	protobufs := "SteamKit/Resources/Protobufs"
+	protobufs := filepath.Join(steamKit, "Resources/Protobufs")
 	protos, err := filepath.Glob(protobufs + "/steamclient/*.proto")
 	if err != nil {
 		panic(err)
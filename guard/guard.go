@@ -0,0 +1,139 @@
+// Implements the Steam Guard mobile authenticator: two-factor logon codes and
+// the keys for trade confirmations.
+package guard
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Characters of the codes, chosen to be hard to confuse.
+const codeChars = "23456789BCDFGHJKMNPQRTVWXY"
+
+const (
+	codeLength = 5
+	// Codes change every period.
+	codePeriod = 30
+	// Tags of confirmation keys are cut off after this many bytes.
+	maxTagLength = 32
+)
+
+// Generates the logon code for the given time from the shared secret.
+func GenerateCode(sharedSecret []byte, t time.Time) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.Unix()/codePeriod))
+	mac := hmac.New(sha1.New, sharedSecret)
+	mac.Write(buf)
+	hash := mac.Sum(nil)
+
+	offset := hash[len(hash)-1] & 0x0f
+	full := binary.BigEndian.Uint32(hash[offset:]) & 0x7fffffff
+	code := make([]byte, codeLength)
+	for i := range code {
+		code[i] = codeChars[full%uint32(len(codeChars))]
+		full /= uint32(len(codeChars))
+	}
+	return string(code)
+}
+
+// Generates the base64 encoded key for a confirmation request with the given
+// tag, e.g. "conf", "details", "allow" or "cancel", at the given time.
+func GenerateConfirmationKey(identitySecret []byte, t time.Time, tag string) string {
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	buf := make([]byte, 8, 8+len(tag))
+	binary.BigEndian.PutUint64(buf, uint64(t.Unix()))
+	buf = append(buf, tag...)
+	mac := hmac.New(sha1.New, identitySecret)
+	mac.Write(buf)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Decodes a secret in the base64 form the mobile app stores it in.
+func DecodeSecret(secret string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(secret)
+}
+
+var ErrNoIdentitySecret = errors.New("No identity secret")
+
+// A mobile authenticator that generates codes based on Steam's time.
+// It implements steamgo.CredentialProvider, so it can be set as the
+// CredentialProvider of the client's Auth to answer two-factor logon requests.
+type Authenticator struct {
+	sharedSecret   []byte
+	identitySecret []byte
+
+	mutex  sync.RWMutex
+	offset time.Duration // Steam's time minus ours
+}
+
+// Creates an authenticator from the base64 encoded secrets of the mobile app.
+// The identity secret is only needed for confirmation keys and may be empty.
+func NewAuthenticator(sharedSecret, identitySecret string) (*Authenticator, error) {
+	shared, err := DecodeSecret(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := DecodeSecret(identitySecret)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{sharedSecret: shared, identitySecret: identity}, nil
+}
+
+// Aligns the authenticator to Steam's current time, e.g. the ServerTime of a
+// LoggedOnEvent. The client does this automatically when Steam reports its time
+// during logon.
+func (a *Authenticator) SetServerTime(serverTime time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.offset = serverTime.Sub(time.Now())
+}
+
+// Returns Steam's current time.
+func (a *Authenticator) Time() time.Time {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return time.Now().Add(a.offset)
+}
+
+// Returns the current logon code.
+func (a *Authenticator) Code() string {
+	return GenerateCode(a.sharedSecret, a.Time())
+}
+
+// Returns the key for a confirmation request with the given tag and the time it
+// was generated for, which must be sent along with it.
+func (a *Authenticator) ConfirmationKey(tag string) (string, time.Time, error) {
+	if len(a.identitySecret) == 0 {
+		return "", time.Time{}, ErrNoIdentitySecret
+	}
+	t := a.Time()
+	return GenerateConfirmationKey(a.identitySecret, t, tag), t, nil
+}
+
+// Returns the current logon code.
+func (a *Authenticator) TwoFactorCode(previousIncorrect bool) (string, error) {
+	if previousIncorrect {
+		// the code may have expired in transit, so wait for the next one
+		a.waitForNextCode()
+	}
+	return a.Code(), nil
+}
+
+// Always fails, since accounts with a mobile authenticator don't get email codes.
+func (a *Authenticator) EmailCode(emailDomain string, previousIncorrect bool) (string, error) {
+	return "", errors.New("Mobile authenticators can't provide email codes")
+}
+
+func (a *Authenticator) waitForNextCode() {
+	now := a.Time()
+	next := time.Unix((now.Unix()/codePeriod+1)*codePeriod, 0)
+	time.Sleep(next.Sub(now))
+}
@@ -0,0 +1,59 @@
+package guard
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	testSharedSecret   = "MDEyMzQ1Njc4OWFiY2RlZmdoaWo="
+	testIdentitySecret = "aWRlbnRpdHkgc2VjcmV0IDEyMzQ="
+)
+
+func TestGenerateCode(t *testing.T) {
+	secret, err := DecodeSecret(testSharedSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		time int64
+		code string
+	}{
+		{1500000000, "5HTKR"},
+		{1500000029, "5HTKR"},
+		{1500000030, "DFKXC"},
+	} {
+		if code := GenerateCode(secret, time.Unix(c.time, 0)); code != c.code {
+			t.Errorf("Expected code %v at %v, got %v", c.code, c.time, code)
+		}
+	}
+}
+
+func TestGenerateConfirmationKey(t *testing.T) {
+	secret, err := DecodeSecret(testIdentitySecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := GenerateConfirmationKey(secret, time.Unix(1500000000, 0), "conf")
+	if key != "ZcHQtuv+NFdGtbr7EtC8AwY62pI=" {
+		t.Fatalf("Wrong confirmation key %v", key)
+	}
+}
+
+func TestAuthenticatorServerTime(t *testing.T) {
+	a, err := NewAuthenticator(testSharedSecret, testIdentitySecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetServerTime(time.Unix(1500000000, 0))
+	if d := a.Time().Sub(time.Unix(1500000000, 0)); d < 0 || d > time.Second {
+		t.Fatalf("Expected the server time, got an offset of %v", d)
+	}
+	if code, _ := a.TwoFactorCode(false); code != "5HTKR" {
+		t.Fatalf("Expected the code at the server time, got %v", code)
+	}
+	key, keyTime, err := a.ConfirmationKey("conf")
+	if err != nil || keyTime.Unix() != 1500000000 || key != "ZcHQtuv+NFdGtbr7EtC8AwY62pI=" {
+		t.Fatalf("Wrong confirmation key %v at %v (%v)", key, keyTime, err)
+	}
+}
@@ -24,6 +24,9 @@ func IsProto(e uint32) bool {
 
 type JobId uint64
 
+// The job id of messages that don't belong to a job.
+const InvalidJobId = ^JobId(0)
+
 type Serializer interface {
 	Serialize(w io.Writer) error
 }
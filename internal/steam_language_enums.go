@@ -1738,6 +1738,7 @@ const (
 	EMsg_ClientPlayingSessionState                                     = 9600
 	EMsg_ClientPlayingSessionKick                                      = 9601 // Deprecated: renamed to ClientKickPlayingSession
 	EMsg_ClientKickPlayingSession                                      = 9601
+	EMsg_ServiceMethodCallFromClientNonAuthed                          = 9804
 )
 
 func (e EMsg) String() string {
@@ -5102,6 +5103,8 @@ func (e EMsg) String() string {
 		return "EMsg_ClientPlayingSessionState"
 	case EMsg_ClientPlayingSessionKick:
 		return "EMsg_ClientPlayingSessionKick"
+	case EMsg_ServiceMethodCallFromClientNonAuthed:
+		return "EMsg_ServiceMethodCallFromClientNonAuthed"
 	default:
 		return "INVALID"
 	}
@@ -5194,6 +5197,10 @@ const (
 	EResult_RestrictedDevice                                = 82
 	EResult_RegionLocked                                    = 83
 	EResult_RateLimitExceeded                               = 84
+	EResult_AccountLoginDeniedNeedTwoFactor                 = 85
+	EResult_ItemDeleted                                     = 86
+	EResult_AccountLoginDeniedThrottle                      = 87
+	EResult_TwoFactorCodeMismatch                           = 88
 )
 
 func (e EResult) String() string {
@@ -5366,6 +5373,14 @@ func (e EResult) String() string {
 		return "EResult_RegionLocked"
 	case EResult_RateLimitExceeded:
 		return "EResult_RateLimitExceeded"
+	case EResult_AccountLoginDeniedNeedTwoFactor:
+		return "EResult_AccountLoginDeniedNeedTwoFactor"
+	case EResult_ItemDeleted:
+		return "EResult_ItemDeleted"
+	case EResult_AccountLoginDeniedThrottle:
+		return "EResult_AccountLoginDeniedThrottle"
+	case EResult_TwoFactorCodeMismatch:
+		return "EResult_TwoFactorCodeMismatch"
 	default:
 		return "INVALID"
 	}
@@ -0,0 +1,772 @@
+// Code generated by protoc-gen-go.
+// source: steammessages_auth.steamclient.proto
+// DO NOT EDIT!
+
+/*
+Package steammessages_auth_steamclient is a generated protocol buffer package.
+
+It is generated from these files:
+	steammessages_auth.steamclient.proto
+
+It has these top-level messages:
+	CAuthentication_GetPasswordRSAPublicKey_Request
+	CAuthentication_GetPasswordRSAPublicKey_Response
+	CAuthentication_DeviceDetails
+	CAuthentication_BeginAuthSessionViaCredentials_Request
+	CAuthentication_BeginAuthSessionViaCredentials_Response
+	CAuthentication_BeginAuthSessionViaQR_Request
+	CAuthentication_BeginAuthSessionViaQR_Response
+	CAuthentication_AllowedConfirmation
+	CAuthentication_PollAuthSessionStatus_Request
+	CAuthentication_PollAuthSessionStatus_Response
+	CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request
+	CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response
+*/
+package internal
+
+import proto "code.google.com/p/goprotobuf/proto"
+import json "encoding/json"
+import math "math"
+
+// Reference proto, json, and math imports to suppress error if they are not otherwise used.
+var _ = proto.Marshal
+var _ = &json.SyntaxError{}
+var _ = math.Inf
+
+type EAuthTokenPlatformType int32
+
+const (
+	EAuthTokenPlatformType_k_EAuthTokenPlatformType_Unknown     EAuthTokenPlatformType = 0
+	EAuthTokenPlatformType_k_EAuthTokenPlatformType_SteamClient EAuthTokenPlatformType = 1
+	EAuthTokenPlatformType_k_EAuthTokenPlatformType_WebBrowser  EAuthTokenPlatformType = 2
+	EAuthTokenPlatformType_k_EAuthTokenPlatformType_MobileApp   EAuthTokenPlatformType = 3
+)
+
+var EAuthTokenPlatformType_name = map[int32]string{
+	0: "k_EAuthTokenPlatformType_Unknown",
+	1: "k_EAuthTokenPlatformType_SteamClient",
+	2: "k_EAuthTokenPlatformType_WebBrowser",
+	3: "k_EAuthTokenPlatformType_MobileApp",
+}
+var EAuthTokenPlatformType_value = map[string]int32{
+	"k_EAuthTokenPlatformType_Unknown":     0,
+	"k_EAuthTokenPlatformType_SteamClient": 1,
+	"k_EAuthTokenPlatformType_WebBrowser":  2,
+	"k_EAuthTokenPlatformType_MobileApp":   3,
+}
+
+func (x EAuthTokenPlatformType) Enum() *EAuthTokenPlatformType {
+	p := new(EAuthTokenPlatformType)
+	*p = x
+	return p
+}
+func (x EAuthTokenPlatformType) String() string {
+	return proto.EnumName(EAuthTokenPlatformType_name, int32(x))
+}
+func (x *EAuthTokenPlatformType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(EAuthTokenPlatformType_value, data, "EAuthTokenPlatformType")
+	if err != nil {
+		return err
+	}
+	*x = EAuthTokenPlatformType(value)
+	return nil
+}
+
+type EAuthSessionGuardType int32
+
+const (
+	EAuthSessionGuardType_k_EAuthSessionGuardType_Unknown            EAuthSessionGuardType = 0
+	EAuthSessionGuardType_k_EAuthSessionGuardType_None               EAuthSessionGuardType = 1
+	EAuthSessionGuardType_k_EAuthSessionGuardType_EmailCode          EAuthSessionGuardType = 2
+	EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceCode         EAuthSessionGuardType = 3
+	EAuthSessionGuardType_k_EAuthSessionGuardType_DeviceConfirmation EAuthSessionGuardType = 4
+	EAuthSessionGuardType_k_EAuthSessionGuardType_EmailConfirmation  EAuthSessionGuardType = 5
+	EAuthSessionGuardType_k_EAuthSessionGuardType_MachineToken       EAuthSessionGuardType = 6
+	EAuthSessionGuardType_k_EAuthSessionGuardType_LegacyMachineAuth  EAuthSessionGuardType = 7
+)
+
+var EAuthSessionGuardType_name = map[int32]string{
+	0: "k_EAuthSessionGuardType_Unknown",
+	1: "k_EAuthSessionGuardType_None",
+	2: "k_EAuthSessionGuardType_EmailCode",
+	3: "k_EAuthSessionGuardType_DeviceCode",
+	4: "k_EAuthSessionGuardType_DeviceConfirmation",
+	5: "k_EAuthSessionGuardType_EmailConfirmation",
+	6: "k_EAuthSessionGuardType_MachineToken",
+	7: "k_EAuthSessionGuardType_LegacyMachineAuth",
+}
+var EAuthSessionGuardType_value = map[string]int32{
+	"k_EAuthSessionGuardType_Unknown":            0,
+	"k_EAuthSessionGuardType_None":               1,
+	"k_EAuthSessionGuardType_EmailCode":          2,
+	"k_EAuthSessionGuardType_DeviceCode":         3,
+	"k_EAuthSessionGuardType_DeviceConfirmation": 4,
+	"k_EAuthSessionGuardType_EmailConfirmation":  5,
+	"k_EAuthSessionGuardType_MachineToken":       6,
+	"k_EAuthSessionGuardType_LegacyMachineAuth":  7,
+}
+
+func (x EAuthSessionGuardType) Enum() *EAuthSessionGuardType {
+	p := new(EAuthSessionGuardType)
+	*p = x
+	return p
+}
+func (x EAuthSessionGuardType) String() string {
+	return proto.EnumName(EAuthSessionGuardType_name, int32(x))
+}
+func (x *EAuthSessionGuardType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(EAuthSessionGuardType_value, data, "EAuthSessionGuardType")
+	if err != nil {
+		return err
+	}
+	*x = EAuthSessionGuardType(value)
+	return nil
+}
+
+type ESessionPersistence int32
+
+const (
+	ESessionPersistence_k_ESessionPersistence_Invalid    ESessionPersistence = -1
+	ESessionPersistence_k_ESessionPersistence_Ephemeral  ESessionPersistence = 0
+	ESessionPersistence_k_ESessionPersistence_Persistent ESessionPersistence = 1
+)
+
+var ESessionPersistence_name = map[int32]string{
+	-1: "k_ESessionPersistence_Invalid",
+	0:  "k_ESessionPersistence_Ephemeral",
+	1:  "k_ESessionPersistence_Persistent",
+}
+var ESessionPersistence_value = map[string]int32{
+	"k_ESessionPersistence_Invalid":    -1,
+	"k_ESessionPersistence_Ephemeral":  0,
+	"k_ESessionPersistence_Persistent": 1,
+}
+
+func (x ESessionPersistence) Enum() *ESessionPersistence {
+	p := new(ESessionPersistence)
+	*p = x
+	return p
+}
+func (x ESessionPersistence) String() string {
+	return proto.EnumName(ESessionPersistence_name, int32(x))
+}
+func (x *ESessionPersistence) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(ESessionPersistence_value, data, "ESessionPersistence")
+	if err != nil {
+		return err
+	}
+	*x = ESessionPersistence(value)
+	return nil
+}
+
+type CAuthentication_GetPasswordRSAPublicKey_Request struct {
+	AccountName      *string `protobuf:"bytes,1,opt,name=account_name" json:"account_name,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Request) Reset() {
+	*m = CAuthentication_GetPasswordRSAPublicKey_Request{}
+}
+func (m *CAuthentication_GetPasswordRSAPublicKey_Request) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_GetPasswordRSAPublicKey_Request) ProtoMessage() {}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Request) GetAccountName() string {
+	if m != nil && m.AccountName != nil {
+		return *m.AccountName
+	}
+	return ""
+}
+
+type CAuthentication_GetPasswordRSAPublicKey_Response struct {
+	PublickeyMod     *string `protobuf:"bytes,1,opt,name=publickey_mod" json:"publickey_mod,omitempty"`
+	PublickeyExp     *string `protobuf:"bytes,2,opt,name=publickey_exp" json:"publickey_exp,omitempty"`
+	Timestamp        *uint64 `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Response) Reset() {
+	*m = CAuthentication_GetPasswordRSAPublicKey_Response{}
+}
+func (m *CAuthentication_GetPasswordRSAPublicKey_Response) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_GetPasswordRSAPublicKey_Response) ProtoMessage() {}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Response) GetPublickeyMod() string {
+	if m != nil && m.PublickeyMod != nil {
+		return *m.PublickeyMod
+	}
+	return ""
+}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Response) GetPublickeyExp() string {
+	if m != nil && m.PublickeyExp != nil {
+		return *m.PublickeyExp
+	}
+	return ""
+}
+
+func (m *CAuthentication_GetPasswordRSAPublicKey_Response) GetTimestamp() uint64 {
+	if m != nil && m.Timestamp != nil {
+		return *m.Timestamp
+	}
+	return 0
+}
+
+type CAuthentication_DeviceDetails struct {
+	DeviceFriendlyName *string                 `protobuf:"bytes,1,opt,name=device_friendly_name" json:"device_friendly_name,omitempty"`
+	PlatformType       *EAuthTokenPlatformType `protobuf:"varint,2,opt,name=platform_type,enum=EAuthTokenPlatformType,def=0" json:"platform_type,omitempty"`
+	OsType             *int32                  `protobuf:"varint,3,opt,name=os_type" json:"os_type,omitempty"`
+	GamingDeviceType   *uint32                 `protobuf:"varint,4,opt,name=gaming_device_type" json:"gaming_device_type,omitempty"`
+	XXX_unrecognized   []byte                  `json:"-"`
+}
+
+func (m *CAuthentication_DeviceDetails) Reset() {
+	*m = CAuthentication_DeviceDetails{}
+}
+func (m *CAuthentication_DeviceDetails) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_DeviceDetails) ProtoMessage() {}
+
+const Default_CAuthentication_DeviceDetails_PlatformType EAuthTokenPlatformType = EAuthTokenPlatformType_k_EAuthTokenPlatformType_Unknown
+
+func (m *CAuthentication_DeviceDetails) GetDeviceFriendlyName() string {
+	if m != nil && m.DeviceFriendlyName != nil {
+		return *m.DeviceFriendlyName
+	}
+	return ""
+}
+
+func (m *CAuthentication_DeviceDetails) GetPlatformType() EAuthTokenPlatformType {
+	if m != nil && m.PlatformType != nil {
+		return *m.PlatformType
+	}
+	return Default_CAuthentication_DeviceDetails_PlatformType
+}
+
+func (m *CAuthentication_DeviceDetails) GetOsType() int32 {
+	if m != nil && m.OsType != nil {
+		return *m.OsType
+	}
+	return 0
+}
+
+func (m *CAuthentication_DeviceDetails) GetGamingDeviceType() uint32 {
+	if m != nil && m.GamingDeviceType != nil {
+		return *m.GamingDeviceType
+	}
+	return 0
+}
+
+type CAuthentication_BeginAuthSessionViaCredentials_Request struct {
+	DeviceFriendlyName  *string                        `protobuf:"bytes,1,opt,name=device_friendly_name" json:"device_friendly_name,omitempty"`
+	AccountName         *string                        `protobuf:"bytes,2,opt,name=account_name" json:"account_name,omitempty"`
+	EncryptedPassword   *string                        `protobuf:"bytes,3,opt,name=encrypted_password" json:"encrypted_password,omitempty"`
+	EncryptionTimestamp *uint64                        `protobuf:"varint,4,opt,name=encryption_timestamp" json:"encryption_timestamp,omitempty"`
+	RememberLogin       *bool                          `protobuf:"varint,5,opt,name=remember_login" json:"remember_login,omitempty"`
+	PlatformType        *EAuthTokenPlatformType        `protobuf:"varint,6,opt,name=platform_type,enum=EAuthTokenPlatformType,def=0" json:"platform_type,omitempty"`
+	Persistence         *ESessionPersistence           `protobuf:"varint,7,opt,name=persistence,enum=ESessionPersistence,def=1" json:"persistence,omitempty"`
+	WebsiteId           *string                        `protobuf:"bytes,8,opt,name=website_id,def=Unknown" json:"website_id,omitempty"`
+	DeviceDetails       *CAuthentication_DeviceDetails `protobuf:"bytes,9,opt,name=device_details" json:"device_details,omitempty"`
+	GuardData           *string                        `protobuf:"bytes,10,opt,name=guard_data" json:"guard_data,omitempty"`
+	Language            *uint32                        `protobuf:"varint,11,opt,name=language" json:"language,omitempty"`
+	QosLevel            *int32                         `protobuf:"varint,12,opt,name=qos_level,def=2" json:"qos_level,omitempty"`
+	XXX_unrecognized    []byte                         `json:"-"`
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) Reset() {
+	*m = CAuthentication_BeginAuthSessionViaCredentials_Request{}
+}
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_BeginAuthSessionViaCredentials_Request) ProtoMessage() {}
+
+const Default_CAuthentication_BeginAuthSessionViaCredentials_Request_PlatformType EAuthTokenPlatformType = EAuthTokenPlatformType_k_EAuthTokenPlatformType_Unknown
+const Default_CAuthentication_BeginAuthSessionViaCredentials_Request_Persistence ESessionPersistence = ESessionPersistence_k_ESessionPersistence_Persistent
+const Default_CAuthentication_BeginAuthSessionViaCredentials_Request_WebsiteId string = "Unknown"
+const Default_CAuthentication_BeginAuthSessionViaCredentials_Request_QosLevel int32 = 2
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetDeviceFriendlyName() string {
+	if m != nil && m.DeviceFriendlyName != nil {
+		return *m.DeviceFriendlyName
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetAccountName() string {
+	if m != nil && m.AccountName != nil {
+		return *m.AccountName
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetEncryptedPassword() string {
+	if m != nil && m.EncryptedPassword != nil {
+		return *m.EncryptedPassword
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetEncryptionTimestamp() uint64 {
+	if m != nil && m.EncryptionTimestamp != nil {
+		return *m.EncryptionTimestamp
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetRememberLogin() bool {
+	if m != nil && m.RememberLogin != nil {
+		return *m.RememberLogin
+	}
+	return false
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetPlatformType() EAuthTokenPlatformType {
+	if m != nil && m.PlatformType != nil {
+		return *m.PlatformType
+	}
+	return Default_CAuthentication_BeginAuthSessionViaCredentials_Request_PlatformType
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetPersistence() ESessionPersistence {
+	if m != nil && m.Persistence != nil {
+		return *m.Persistence
+	}
+	return Default_CAuthentication_BeginAuthSessionViaCredentials_Request_Persistence
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetWebsiteId() string {
+	if m != nil && m.WebsiteId != nil {
+		return *m.WebsiteId
+	}
+	return Default_CAuthentication_BeginAuthSessionViaCredentials_Request_WebsiteId
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetDeviceDetails() *CAuthentication_DeviceDetails {
+	if m != nil {
+		return m.DeviceDetails
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetGuardData() string {
+	if m != nil && m.GuardData != nil {
+		return *m.GuardData
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetLanguage() uint32 {
+	if m != nil && m.Language != nil {
+		return *m.Language
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Request) GetQosLevel() int32 {
+	if m != nil && m.QosLevel != nil {
+		return *m.QosLevel
+	}
+	return Default_CAuthentication_BeginAuthSessionViaCredentials_Request_QosLevel
+}
+
+type CAuthentication_BeginAuthSessionViaCredentials_Response struct {
+	ClientId             *uint64                                `protobuf:"varint,1,opt,name=client_id" json:"client_id,omitempty"`
+	RequestId            []byte                                 `protobuf:"bytes,2,opt,name=request_id" json:"request_id,omitempty"`
+	Interval             *float32                               `protobuf:"fixed32,3,opt,name=interval" json:"interval,omitempty"`
+	AllowedConfirmations []*CAuthentication_AllowedConfirmation `protobuf:"bytes,4,rep,name=allowed_confirmations" json:"allowed_confirmations,omitempty"`
+	Steamid              *uint64                                `protobuf:"varint,5,opt,name=steamid" json:"steamid,omitempty"`
+	WeakToken            *string                                `protobuf:"bytes,6,opt,name=weak_token" json:"weak_token,omitempty"`
+	AgreementSessionUrl  *string                                `protobuf:"bytes,7,opt,name=agreement_session_url" json:"agreement_session_url,omitempty"`
+	ExtendedErrorMessage *string                                `protobuf:"bytes,8,opt,name=extended_error_message" json:"extended_error_message,omitempty"`
+	XXX_unrecognized     []byte                                 `json:"-"`
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) Reset() {
+	*m = CAuthentication_BeginAuthSessionViaCredentials_Response{}
+}
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_BeginAuthSessionViaCredentials_Response) ProtoMessage() {}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetClientId() uint64 {
+	if m != nil && m.ClientId != nil {
+		return *m.ClientId
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetRequestId() []byte {
+	if m != nil {
+		return m.RequestId
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetInterval() float32 {
+	if m != nil && m.Interval != nil {
+		return *m.Interval
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetAllowedConfirmations() []*CAuthentication_AllowedConfirmation {
+	if m != nil {
+		return m.AllowedConfirmations
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetSteamid() uint64 {
+	if m != nil && m.Steamid != nil {
+		return *m.Steamid
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetWeakToken() string {
+	if m != nil && m.WeakToken != nil {
+		return *m.WeakToken
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetAgreementSessionUrl() string {
+	if m != nil && m.AgreementSessionUrl != nil {
+		return *m.AgreementSessionUrl
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaCredentials_Response) GetExtendedErrorMessage() string {
+	if m != nil && m.ExtendedErrorMessage != nil {
+		return *m.ExtendedErrorMessage
+	}
+	return ""
+}
+
+type CAuthentication_BeginAuthSessionViaQR_Request struct {
+	DeviceFriendlyName *string                        `protobuf:"bytes,1,opt,name=device_friendly_name" json:"device_friendly_name,omitempty"`
+	PlatformType       *EAuthTokenPlatformType        `protobuf:"varint,2,opt,name=platform_type,enum=EAuthTokenPlatformType,def=0" json:"platform_type,omitempty"`
+	DeviceDetails      *CAuthentication_DeviceDetails `protobuf:"bytes,3,opt,name=device_details" json:"device_details,omitempty"`
+	WebsiteId          *string                        `protobuf:"bytes,4,opt,name=website_id,def=Unknown" json:"website_id,omitempty"`
+	XXX_unrecognized   []byte                         `json:"-"`
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) Reset() {
+	*m = CAuthentication_BeginAuthSessionViaQR_Request{}
+}
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_BeginAuthSessionViaQR_Request) ProtoMessage() {}
+
+const Default_CAuthentication_BeginAuthSessionViaQR_Request_PlatformType EAuthTokenPlatformType = EAuthTokenPlatformType_k_EAuthTokenPlatformType_Unknown
+const Default_CAuthentication_BeginAuthSessionViaQR_Request_WebsiteId string = "Unknown"
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) GetDeviceFriendlyName() string {
+	if m != nil && m.DeviceFriendlyName != nil {
+		return *m.DeviceFriendlyName
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) GetPlatformType() EAuthTokenPlatformType {
+	if m != nil && m.PlatformType != nil {
+		return *m.PlatformType
+	}
+	return Default_CAuthentication_BeginAuthSessionViaQR_Request_PlatformType
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) GetDeviceDetails() *CAuthentication_DeviceDetails {
+	if m != nil {
+		return m.DeviceDetails
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Request) GetWebsiteId() string {
+	if m != nil && m.WebsiteId != nil {
+		return *m.WebsiteId
+	}
+	return Default_CAuthentication_BeginAuthSessionViaQR_Request_WebsiteId
+}
+
+type CAuthentication_BeginAuthSessionViaQR_Response struct {
+	ClientId             *uint64                                `protobuf:"varint,1,opt,name=client_id" json:"client_id,omitempty"`
+	ChallengeUrl         *string                                `protobuf:"bytes,2,opt,name=challenge_url" json:"challenge_url,omitempty"`
+	RequestId            []byte                                 `protobuf:"bytes,3,opt,name=request_id" json:"request_id,omitempty"`
+	Interval             *float32                               `protobuf:"fixed32,4,opt,name=interval" json:"interval,omitempty"`
+	AllowedConfirmations []*CAuthentication_AllowedConfirmation `protobuf:"bytes,5,rep,name=allowed_confirmations" json:"allowed_confirmations,omitempty"`
+	Version              *int32                                 `protobuf:"varint,6,opt,name=version" json:"version,omitempty"`
+	XXX_unrecognized     []byte                                 `json:"-"`
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) Reset() {
+	*m = CAuthentication_BeginAuthSessionViaQR_Response{}
+}
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_BeginAuthSessionViaQR_Response) ProtoMessage() {}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetClientId() uint64 {
+	if m != nil && m.ClientId != nil {
+		return *m.ClientId
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetChallengeUrl() string {
+	if m != nil && m.ChallengeUrl != nil {
+		return *m.ChallengeUrl
+	}
+	return ""
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetRequestId() []byte {
+	if m != nil {
+		return m.RequestId
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetInterval() float32 {
+	if m != nil && m.Interval != nil {
+		return *m.Interval
+	}
+	return 0
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetAllowedConfirmations() []*CAuthentication_AllowedConfirmation {
+	if m != nil {
+		return m.AllowedConfirmations
+	}
+	return nil
+}
+
+func (m *CAuthentication_BeginAuthSessionViaQR_Response) GetVersion() int32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
+
+type CAuthentication_AllowedConfirmation struct {
+	ConfirmationType  *EAuthSessionGuardType `protobuf:"varint,1,opt,name=confirmation_type,enum=EAuthSessionGuardType,def=0" json:"confirmation_type,omitempty"`
+	AssociatedMessage *string                `protobuf:"bytes,2,opt,name=associated_message" json:"associated_message,omitempty"`
+	XXX_unrecognized  []byte                 `json:"-"`
+}
+
+func (m *CAuthentication_AllowedConfirmation) Reset() {
+	*m = CAuthentication_AllowedConfirmation{}
+}
+func (m *CAuthentication_AllowedConfirmation) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_AllowedConfirmation) ProtoMessage() {}
+
+const Default_CAuthentication_AllowedConfirmation_ConfirmationType EAuthSessionGuardType = EAuthSessionGuardType_k_EAuthSessionGuardType_Unknown
+
+func (m *CAuthentication_AllowedConfirmation) GetConfirmationType() EAuthSessionGuardType {
+	if m != nil && m.ConfirmationType != nil {
+		return *m.ConfirmationType
+	}
+	return Default_CAuthentication_AllowedConfirmation_ConfirmationType
+}
+
+func (m *CAuthentication_AllowedConfirmation) GetAssociatedMessage() string {
+	if m != nil && m.AssociatedMessage != nil {
+		return *m.AssociatedMessage
+	}
+	return ""
+}
+
+type CAuthentication_PollAuthSessionStatus_Request struct {
+	ClientId         *uint64 `protobuf:"varint,1,opt,name=client_id" json:"client_id,omitempty"`
+	RequestId        []byte  `protobuf:"bytes,2,opt,name=request_id" json:"request_id,omitempty"`
+	TokenToRevoke    *uint64 `protobuf:"fixed64,3,opt,name=token_to_revoke" json:"token_to_revoke,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Request) Reset() {
+	*m = CAuthentication_PollAuthSessionStatus_Request{}
+}
+func (m *CAuthentication_PollAuthSessionStatus_Request) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_PollAuthSessionStatus_Request) ProtoMessage() {}
+
+func (m *CAuthentication_PollAuthSessionStatus_Request) GetClientId() uint64 {
+	if m != nil && m.ClientId != nil {
+		return *m.ClientId
+	}
+	return 0
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Request) GetRequestId() []byte {
+	if m != nil {
+		return m.RequestId
+	}
+	return nil
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Request) GetTokenToRevoke() uint64 {
+	if m != nil && m.TokenToRevoke != nil {
+		return *m.TokenToRevoke
+	}
+	return 0
+}
+
+type CAuthentication_PollAuthSessionStatus_Response struct {
+	NewClientId          *uint64 `protobuf:"varint,1,opt,name=new_client_id" json:"new_client_id,omitempty"`
+	NewChallengeUrl      *string `protobuf:"bytes,2,opt,name=new_challenge_url" json:"new_challenge_url,omitempty"`
+	RefreshToken         *string `protobuf:"bytes,3,opt,name=refresh_token" json:"refresh_token,omitempty"`
+	AccessToken          *string `protobuf:"bytes,4,opt,name=access_token" json:"access_token,omitempty"`
+	HadRemoteInteraction *bool   `protobuf:"varint,5,opt,name=had_remote_interaction" json:"had_remote_interaction,omitempty"`
+	AccountName          *string `protobuf:"bytes,6,opt,name=account_name" json:"account_name,omitempty"`
+	NewGuardData         *string `protobuf:"bytes,7,opt,name=new_guard_data" json:"new_guard_data,omitempty"`
+	AgreementSessionUrl  *string `protobuf:"bytes,8,opt,name=agreement_session_url" json:"agreement_session_url,omitempty"`
+	XXX_unrecognized     []byte  `json:"-"`
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) Reset() {
+	*m = CAuthentication_PollAuthSessionStatus_Response{}
+}
+func (m *CAuthentication_PollAuthSessionStatus_Response) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_PollAuthSessionStatus_Response) ProtoMessage() {}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetNewClientId() uint64 {
+	if m != nil && m.NewClientId != nil {
+		return *m.NewClientId
+	}
+	return 0
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetNewChallengeUrl() string {
+	if m != nil && m.NewChallengeUrl != nil {
+		return *m.NewChallengeUrl
+	}
+	return ""
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetRefreshToken() string {
+	if m != nil && m.RefreshToken != nil {
+		return *m.RefreshToken
+	}
+	return ""
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetAccessToken() string {
+	if m != nil && m.AccessToken != nil {
+		return *m.AccessToken
+	}
+	return ""
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetHadRemoteInteraction() bool {
+	if m != nil && m.HadRemoteInteraction != nil {
+		return *m.HadRemoteInteraction
+	}
+	return false
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetAccountName() string {
+	if m != nil && m.AccountName != nil {
+		return *m.AccountName
+	}
+	return ""
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetNewGuardData() string {
+	if m != nil && m.NewGuardData != nil {
+		return *m.NewGuardData
+	}
+	return ""
+}
+
+func (m *CAuthentication_PollAuthSessionStatus_Response) GetAgreementSessionUrl() string {
+	if m != nil && m.AgreementSessionUrl != nil {
+		return *m.AgreementSessionUrl
+	}
+	return ""
+}
+
+type CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request struct {
+	ClientId         *uint64                `protobuf:"varint,1,opt,name=client_id" json:"client_id,omitempty"`
+	Steamid          *uint64                `protobuf:"fixed64,2,opt,name=steamid" json:"steamid,omitempty"`
+	Code             *string                `protobuf:"bytes,3,opt,name=code" json:"code,omitempty"`
+	CodeType         *EAuthSessionGuardType `protobuf:"varint,4,opt,name=code_type,enum=EAuthSessionGuardType,def=0" json:"code_type,omitempty"`
+	XXX_unrecognized []byte                 `json:"-"`
+}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) Reset() {
+	*m = CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request{}
+}
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) ProtoMessage() {}
+
+const Default_CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request_CodeType EAuthSessionGuardType = EAuthSessionGuardType_k_EAuthSessionGuardType_Unknown
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) GetClientId() uint64 {
+	if m != nil && m.ClientId != nil {
+		return *m.ClientId
+	}
+	return 0
+}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) GetSteamid() uint64 {
+	if m != nil && m.Steamid != nil {
+		return *m.Steamid
+	}
+	return 0
+}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) GetCode() string {
+	if m != nil && m.Code != nil {
+		return *m.Code
+	}
+	return ""
+}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request) GetCodeType() EAuthSessionGuardType {
+	if m != nil && m.CodeType != nil {
+		return *m.CodeType
+	}
+	return Default_CAuthentication_UpdateAuthSessionWithSteamGuardCode_Request_CodeType
+}
+
+type CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response struct {
+	AgreementSessionUrl *string `protobuf:"bytes,7,opt,name=agreement_session_url" json:"agreement_session_url,omitempty"`
+	XXX_unrecognized    []byte  `json:"-"`
+}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response) Reset() {
+	*m = CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response{}
+}
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response) String() string {
+	return proto.CompactTextString(m)
+}
+func (*CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response) ProtoMessage() {}
+
+func (m *CAuthentication_UpdateAuthSessionWithSteamGuardCode_Response) GetAgreementSessionUrl() string {
+	if m != nil && m.AgreementSessionUrl != nil {
+		return *m.AgreementSessionUrl
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterEnum("EAuthTokenPlatformType", EAuthTokenPlatformType_name, EAuthTokenPlatformType_value)
+	proto.RegisterEnum("EAuthSessionGuardType", EAuthSessionGuardType_name, EAuthSessionGuardType_value)
+	proto.RegisterEnum("ESessionPersistence", ESessionPersistence_name, ESessionPersistence_value)
+}
@@ -662,6 +662,8 @@ type CMsgClientLogon struct {
 	CountryOverride                   *string `protobuf:"bytes,98,opt,name=country_override" json:"country_override,omitempty"`
 	IsSteamBox                        *bool   `protobuf:"varint,99,opt,name=is_steam_box" json:"is_steam_box,omitempty"`
 	ClientInstanceId                  *uint64 `protobuf:"varint,100,opt,name=client_instance_id" json:"client_instance_id,omitempty"`
+	TwoFactorCode                     *string `protobuf:"bytes,101,opt,name=two_factor_code" json:"two_factor_code,omitempty"`
+	AccessToken                       *string `protobuf:"bytes,108,opt,name=access_token" json:"access_token,omitempty"`
 	XXX_unrecognized                  []byte  `json:"-"`
 }
 
@@ -983,6 +985,20 @@ func (m *CMsgClientLogon) GetClientInstanceId() uint64 {
 	return 0
 }
 
+func (m *CMsgClientLogon) GetTwoFactorCode() string {
+	if m != nil && m.TwoFactorCode != nil {
+		return *m.TwoFactorCode
+	}
+	return ""
+}
+
+func (m *CMsgClientLogon) GetAccessToken() string {
+	if m != nil && m.AccessToken != nil {
+		return *m.AccessToken
+	}
+	return ""
+}
+
 type CMsgClientLogonResponse struct {
 	Eresult                     *int32  `protobuf:"varint,1,opt,name=eresult,def=2" json:"eresult,omitempty"`
 	OutOfGameHeartbeatSeconds   *int32  `protobuf:"varint,2,opt,name=out_of_game_heartbeat_seconds" json:"out_of_game_heartbeat_seconds,omitempty"`
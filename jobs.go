@@ -0,0 +1,177 @@
+package steamgo
+
+import (
+	"context"
+	"errors"
+	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
+	"time"
+)
+
+var (
+	// Steam couldn't deliver the request to the service handling it (EMsg_DestJobFailed).
+	ErrJobFailed = errors.New("Job failed")
+	// Neither a response nor a heartbeat arrived within the client's JobTimeout.
+	ErrJobTimeout = errors.New("Job timed out")
+	// The connection was closed before the job was finished.
+	ErrJobAborted = errors.New("Job aborted")
+)
+
+// The time a job may go without a response or heartbeat if the client's JobTimeout is zero.
+const DefaultJobTimeout = 30 * time.Second
+
+// A request sent to Steam, resolved by the responses whose target job id matches its id.
+type Job struct {
+	Id JobId
+
+	manager  *jobManager
+	finished func(*PacketMsg) bool
+	timeout  time.Duration
+	timer    *time.Timer
+	done     chan struct{}
+	// only written while pending, by the manager
+	packets []*PacketMsg
+	err     error
+}
+
+// Returns a channel that is closed once the job is finished or failed.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Waits for the job and returns its last response. If ctx is done first,
+// the job is cancelled and the context's error is returned.
+func (j *Job) Wait(ctx context.Context) (*PacketMsg, error) {
+	packets, err := j.WaitAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return packets[len(packets)-1], nil
+}
+
+// Waits for the job and returns all responses in the order they arrived.
+// If ctx is done first, the job is cancelled and the context's error is returned.
+func (j *Job) WaitAll(ctx context.Context) ([]*PacketMsg, error) {
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		j.manager.finish(j, ctx.Err())
+		<-j.done
+	}
+	return j.packets, j.err
+}
+
+// Stops waiting for responses; Wait returns context.Canceled.
+func (j *Job) Cancel() {
+	j.manager.finish(j, context.Canceled)
+}
+
+// Keeps track of the pending jobs of a client.
+type jobManager struct {
+	mutex sync.Mutex
+	jobs  map[JobId]*Job
+}
+
+func newJobManager() *jobManager {
+	return &jobManager{jobs: make(map[JobId]*Job)}
+}
+
+func (m *jobManager) add(job *Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs[job.Id] = job
+	job.timer = time.AfterFunc(job.timeout, func() {
+		m.finish(job, ErrJobTimeout)
+	})
+}
+
+// Resolves the job the packet belongs to, if any. Heartbeats only extend its timeout.
+func (m *jobManager) handle(packet *PacketMsg) {
+	m.mutex.Lock()
+	job, ok := m.jobs[packet.TargetJobId]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	switch packet.EMsg {
+	case EMsg_JobHeartbeat:
+		job.timer.Reset(job.timeout)
+		m.mutex.Unlock()
+		return
+	case EMsg_DestJobFailed:
+		m.mutex.Unlock()
+		m.finish(job, ErrJobFailed)
+		return
+	}
+	job.packets = append(job.packets, packet)
+	job.timer.Reset(job.timeout)
+	m.mutex.Unlock()
+
+	if job.finished == nil || job.finished(packet) {
+		m.finish(job, nil)
+	}
+}
+
+// Removes the job and wakes up its waiters, unless it is already finished.
+func (m *jobManager) finish(job *Job, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.jobs[job.Id] != job {
+		return
+	}
+	delete(m.jobs, job.Id)
+	job.timer.Stop()
+	job.err = err
+	close(job.done)
+}
+
+// Fails all pending jobs.
+func (m *jobManager) failAll(err error) {
+	m.mutex.Lock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mutex.Unlock()
+	for _, job := range jobs {
+		m.finish(job, err)
+	}
+}
+
+// Sends msg with a new source job id and returns the job, which is finished
+// by the first response.
+func (c *Client) SendJob(msg IMsg) *Job {
+	return c.SendJobMultiple(msg, nil)
+}
+
+// Sends msg with a new source job id and returns the job, which collects all
+// responses until finished returns true for one of them. Use this for requests
+// Steam answers with multiple messages.
+//
+// Like Write, it doesn't send anything before the ConnectedEvent; the job then
+// fails with ErrJobAborted right away.
+func (c *Client) SendJobMultiple(msg IMsg, finished func(*PacketMsg) bool) *Job {
+	timeout := c.JobTimeout
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+	job := &Job{
+		Id:       c.GetNextJobId(),
+		manager:  c.jobs,
+		finished: finished,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+	}
+	msg.SetSourceJobId(job.Id)
+	c.jobs.add(job)
+	if !c.write(msg) {
+		// not connected or the handshake isn't finished, so nobody would ever answer
+		c.jobs.finish(job, ErrJobAborted)
+	}
+	return job
+}
+
+// Sends msg as a job and waits for its response.
+func (c *Client) Request(ctx context.Context, msg IMsg) (*PacketMsg, error) {
+	return c.SendJob(msg).Wait(ctx)
+}
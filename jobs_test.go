@@ -0,0 +1,165 @@
+package steamgo
+
+import (
+	"context"
+	. "github.com/gamingrobot/steamgo/internal"
+	"testing"
+	"time"
+)
+
+// Adds a job to the manager like SendJobMultiple, without sending anything.
+func addJob(m *jobManager, id JobId, timeout time.Duration, finished func(*PacketMsg) bool) *Job {
+	job := &Job{
+		Id:       id,
+		manager:  m,
+		finished: finished,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+	}
+	m.add(job)
+	return job
+}
+
+func waitJob(t *testing.T, job *Job) ([]*PacketMsg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return job.WaitAll(ctx)
+}
+
+func TestJobResponse(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, time.Minute, nil)
+	// responses to other jobs are ignored
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 2})
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 1})
+
+	packets, err := waitJob(t, job)
+	if err != nil || len(packets) != 1 || packets[0].TargetJobId != 1 {
+		t.Fatalf("Expected the response, got %v, %v", packets, err)
+	}
+	// later responses have no effect
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 1})
+	if packets, _ := job.WaitAll(context.Background()); len(packets) != 1 {
+		t.Fatalf("Expected one response, got %v", len(packets))
+	}
+}
+
+func TestJobMultipleResponses(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, time.Minute, func(packet *PacketMsg) bool {
+		return packet.EMsg == EMsg_ClientLogOnResponse
+	})
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 1})
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 1})
+	select {
+	case <-job.Done():
+		t.Fatal("Expected the job to wait for the last response")
+	default:
+	}
+	m.handle(&PacketMsg{EMsg: EMsg_ClientLogOnResponse, TargetJobId: 1})
+
+	packets, err := waitJob(t, job)
+	if err != nil || len(packets) != 3 || packets[2].EMsg != EMsg_ClientLogOnResponse {
+		t.Fatalf("Expected all three responses in order, got %v, %v", packets, err)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, 20*time.Millisecond, nil)
+	if _, err := waitJob(t, job); err != ErrJobTimeout {
+		t.Fatalf("Expected ErrJobTimeout, got %v", err)
+	}
+}
+
+func TestJobHeartbeatExtendsTimeout(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, 100*time.Millisecond, nil)
+	// several timeouts pass, but the job is never idle for that long
+	for i := 0; i < 8; i++ {
+		time.Sleep(25 * time.Millisecond)
+		m.handle(&PacketMsg{EMsg: EMsg_JobHeartbeat, TargetJobId: 1})
+	}
+	select {
+	case <-job.Done():
+		t.Fatalf("Expected the heartbeats to keep the job alive, got %v", job.err)
+	default:
+	}
+
+	m.handle(&PacketMsg{EMsg: EMsg_ClientServiceMethodResponse, TargetJobId: 1})
+	packets, err := waitJob(t, job)
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("Expected only the response, got %v, %v", packets, err)
+	}
+}
+
+func TestJobDestJobFailed(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, time.Minute, nil)
+	m.handle(&PacketMsg{EMsg: EMsg_DestJobFailed, TargetJobId: 1})
+	if _, err := waitJob(t, job); err != ErrJobFailed {
+		t.Fatalf("Expected ErrJobFailed, got %v", err)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	m := newJobManager()
+	job := addJob(m, 1, time.Minute, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := job.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+
+	job = addJob(m, 2, time.Minute, nil)
+	job.Cancel()
+	if _, err := waitJob(t, job); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(m.jobs) != 0 {
+		t.Fatalf("Expected finished jobs to be removed, got %v", m.jobs)
+	}
+}
+
+func TestJobsFailOnDisconnect(t *testing.T) {
+	m := newJobManager()
+	first, second := addJob(m, 1, time.Minute, nil), addJob(m, 2, time.Minute, nil)
+	m.failAll(ErrJobAborted)
+	for _, job := range []*Job{first, second} {
+		if _, err := waitJob(t, job); err != ErrJobAborted {
+			t.Fatalf("Expected ErrJobAborted, got %v", err)
+		}
+	}
+
+	// nobody would answer a job sent while disconnected
+	client := NewClient()
+	if _, err := waitJob(t, client.SendJob(NewClientMsgProtobuf(EMsg_ClientServiceMethod, new(CMsgClientServiceMethod)))); err != ErrJobAborted {
+		t.Fatalf("Expected ErrJobAborted, got %v", err)
+	}
+}
+
+func TestJobsFailBeforeHandshake(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+	s := client.currentSession()
+	s.mutex.Lock()
+	s.secure = false
+	s.mutex.Unlock()
+
+	// connected, but the message would be dropped
+	job := client.SendJob(NewClientMsgProtobuf(EMsg_ClientServiceMethod, new(CMsgClientServiceMethod)))
+	select {
+	case <-job.Done():
+	default:
+		t.Fatal("Expected the job to fail right away")
+	}
+	if _, err := waitJob(t, job); err != ErrJobAborted {
+		t.Fatalf("Expected ErrJobAborted, got %v", err)
+	}
+	select {
+	case packet := <-conn.written:
+		t.Fatalf("Expected nothing to be sent, got %v", packet.EMsg)
+	default:
+	}
+}
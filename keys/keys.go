@@ -4,8 +4,26 @@ import (
 	"crypto/rsa"
 	"github.com/gamingrobot/steamgo/cryptoutil"
 	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
 )
 
+var (
+	overrideMutex sync.RWMutex
+	overrides     = make(map[EUniverse]*rsa.PublicKey)
+)
+
+// Replaces the public key of the universe for the whole process, e.g. to connect
+// to a fake CM in tests. Passing nil restores Steam's key.
+func SetPublicKey(universe EUniverse, key *rsa.PublicKey) {
+	overrideMutex.Lock()
+	defer overrideMutex.Unlock()
+	if key == nil {
+		delete(overrides, universe)
+	} else {
+		overrides[universe] = key
+	}
+}
+
 var publicKeys = map[EUniverse][]byte{
 	EUniverse_Public: []byte{
 		0x30, 0x81, 0x9D, 0x30, 0x0D, 0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x01, 0x01,
@@ -46,6 +64,13 @@ var publicKeys = map[EUniverse][]byte{
 }
 
 func GetPublicKey(universe EUniverse) *rsa.PublicKey {
+	overrideMutex.RLock()
+	key, ok := overrides[universe]
+	overrideMutex.RUnlock()
+	if ok {
+		return key
+	}
+
 	bytes, ok := publicKeys[universe]
 	if !ok {
 		return nil
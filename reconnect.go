@@ -0,0 +1,229 @@
+package steamgo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Controls how a Client reconnects after losing its connection.
+// Zero durations are replaced by the values of DefaultReconnectPolicy.
+type ReconnectPolicy struct {
+	// Delay before the first attempt. It doubles with every failed attempt.
+	MinDelay time.Duration
+	// Upper bound for the delay between two attempts.
+	MaxDelay time.Duration
+	// Randomizes each delay by up to this fraction (0 to 1) in either direction,
+	// so that many clients don't reconnect at the same time.
+	Jitter float64
+	// Number of attempts before giving up with a FatalError. Zero means unlimited.
+	MaxAttempts int
+}
+
+// Limits every single attempt, unless the client's ConnectionTimeout is shorter.
+const reconnectAttemptTimeout = 30 * time.Second
+
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MinDelay: time.Second,
+	MaxDelay: 2 * time.Minute,
+	Jitter:   0.2,
+}
+
+// Returns the delay before the given attempt, starting at 1.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	min, max := p.MinDelay, p.MaxDelay
+	if min <= 0 {
+		min = DefaultReconnectPolicy.MinDelay
+	}
+	if max <= 0 {
+		max = DefaultReconnectPolicy.MaxDelay
+	}
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// Enables reconnecting automatically. When the connection is lost or Steam asks us
+// to try another CM, the client connects to a different server after a backoff
+// delay and logs on again with the details last passed to Auth.LogOn.
+//
+// While enabled, lost connections emit a ReconnectingEvent instead of a FatalError
+// for every attempt and a ReconnectedEvent once the client is logged on again.
+func (c *Client) EnableReconnect(policy ReconnectPolicy) {
+	c.reconnectMutex.Lock()
+	defer c.reconnectMutex.Unlock()
+	if c.reconnectPolicy == nil {
+		c.reconnectStop = make(chan struct{})
+	}
+	c.reconnectPolicy = &policy
+}
+
+// Disables reconnecting and aborts a pending reconnect.
+func (c *Client) DisableReconnect() {
+	c.reconnectMutex.Lock()
+	defer c.reconnectMutex.Unlock()
+	if c.reconnectPolicy == nil {
+		return
+	}
+	c.reconnectPolicy = nil
+	close(c.reconnectStop)
+}
+
+// Closes the connection of the session, or of the current one if s is nil, and
+// starts reconnecting if it is enabled. Returns false, leaving the connection
+// alone, if it is disabled or the connection was passed to ConnectWith.
+func (c *Client) connectionLost(s *session, reason error) bool {
+	if s == nil {
+		s = c.currentSession()
+	}
+	if s != nil && s.address == "" {
+		// we can't open it again
+		return false
+	}
+	c.reconnectMutex.Lock()
+	if c.reconnectPolicy == nil {
+		c.reconnectMutex.Unlock()
+		return false
+	}
+	start := !c.reconnecting
+	c.reconnecting = true
+	if start {
+		c.reconnects.Add(1)
+	}
+	c.reconnectMutex.Unlock()
+
+	c.endSession(s)
+	if start {
+		go c.reconnectLoop(reason)
+	}
+	return true
+}
+
+func (c *Client) reconnectLoop(reason error) {
+	defer c.reconnects.Done()
+	defer func() {
+		c.reconnectMutex.Lock()
+		c.reconnecting = false
+		c.reconnectMutex.Unlock()
+	}()
+
+	for {
+		c.reconnectMutex.Lock()
+		if c.reconnectPolicy == nil {
+			c.reconnectAttempt = 0
+			c.reconnectMutex.Unlock()
+			return
+		}
+		policy, stop := *c.reconnectPolicy, c.reconnectStop
+		c.reconnectAttempt++
+		attempt := c.reconnectAttempt
+		if policy.MaxAttempts > 0 && attempt > policy.MaxAttempts {
+			c.reconnectAttempt = 0
+			c.reconnectMutex.Unlock()
+			c.Emit(FatalError(fmt.Errorf("Giving up after %d reconnect attempts: %v", policy.MaxAttempts, reason)))
+			return
+		}
+		c.reconnectMutex.Unlock()
+
+		server := c.nextServer()
+		delay := policy.delay(attempt)
+		c.Emit(ReconnectingEvent{
+			Attempt: attempt,
+			Server:  server,
+			Delay:   delay,
+			Reason:  reason,
+		})
+
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		}
+
+		ctx, cancel := attemptContext(context.Background(), stop)
+		err := c.ConnectContext(ctx, server)
+		cancel()
+		if err != nil {
+			reason = err
+			continue
+		}
+		// if we log on again, the ReconnectedEvent follows the LoggedOnEvent
+		if !c.Auth.relogOn() {
+			c.reconnected()
+		}
+		return
+	}
+}
+
+// Returns a context for a single connection attempt, which is done after
+// reconnectAttemptTimeout or once stop is closed. stop may be nil.
+func attemptContext(parent context.Context, stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, reconnectAttemptTimeout)
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Returns the channel closed by DisableReconnect, or nil if reconnecting is disabled.
+func (c *Client) reconnectStopChan() <-chan struct{} {
+	c.reconnectMutex.Lock()
+	defer c.reconnectMutex.Unlock()
+	if c.reconnectPolicy == nil {
+		return nil
+	}
+	return c.reconnectStop
+}
+
+// Emits a ReconnectedEvent if the client was reconnecting.
+func (c *Client) reconnected() {
+	c.reconnectMutex.Lock()
+	attempts := c.reconnectAttempt
+	c.reconnectAttempt = 0
+	c.reconnectMutex.Unlock()
+	if attempts == 0 {
+		return
+	}
+
+	c.mutex.RLock()
+	server := c.address
+	c.mutex.RUnlock()
+	c.Emit(ReconnectedEvent{
+		Attempts: attempts,
+		Server:   server,
+	})
+}
+
+// Picks the healthiest CM other than the one we were last connected to,
+// keeping the transport.
+func (c *Client) nextServer() string {
+	c.mutex.RLock()
+	last := c.address
+	c.mutex.RUnlock()
+
+	scheme := ""
+	if i := strings.Index(last, "://"); i >= 0 {
+		scheme = last[:i+3]
+		if scheme != "udp://" && scheme != "tcp://" {
+			// we don't know any other servers for this transport
+			return last
+		}
+		last = last[i+3:]
+	}
+
+	return scheme + c.serverList().Best(last)
+}
@@ -0,0 +1,123 @@
+package steamgo
+
+import (
+	"errors"
+	"github.com/gamingrobot/steamgo/servers"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	policy := ReconnectPolicy{MinDelay: time.Second, MaxDelay: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, delay := range expected {
+		if d := policy.delay(i + 1); d != delay {
+			t.Errorf("Expected %v before attempt %v, got %v", delay, i+1, d)
+		}
+	}
+	if d := policy.delay(1000); d != 10*time.Second {
+		t.Errorf("Expected the delay to stay capped, got %v", d)
+	}
+
+	// zero durations are replaced by the defaults
+	if d := (ReconnectPolicy{}).delay(1); d != DefaultReconnectPolicy.MinDelay {
+		t.Errorf("Expected the default MinDelay, got %v", d)
+	}
+	if d := (ReconnectPolicy{}).delay(1000); d != DefaultReconnectPolicy.MaxDelay {
+		t.Errorf("Expected the default MaxDelay, got %v", d)
+	}
+}
+
+func TestReconnectJitter(t *testing.T) {
+	policy := ReconnectPolicy{MinDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		d := policy.delay(2)
+		if d < time.Second || d > 3*time.Second {
+			t.Fatalf("Expected the delay to be within 50%% of 2s, got %v", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 100 {
+		t.Fatalf("Expected randomized delays, got %v different ones", len(seen))
+	}
+}
+
+func TestNextServerKeepsScheme(t *testing.T) {
+	client := NewClient()
+	client.Servers, _ = servers.NewServerList(nil)
+	client.Servers.ReportSuccess("1.2.3.4:27017", time.Millisecond)
+	client.Servers.ReportSuccess("5.6.7.8:27017", time.Millisecond)
+
+	tests := []struct {
+		last, next string
+	}{
+		{"5.6.7.8:27017", "1.2.3.4:27017"},
+		{"tcp://5.6.7.8:27017", "tcp://1.2.3.4:27017"},
+		{"udp://1.2.3.4:27017", "udp://5.6.7.8:27017"},
+		// only TCP and UDP servers are known
+		{"wss://cm.example.com:443/cmsocket/", "wss://cm.example.com:443/cmsocket/"},
+	}
+	for _, test := range tests {
+		client.address = test.last
+		if next := client.nextServer(); next != test.next {
+			t.Errorf("Expected %v after %v, got %v", test.next, test.last, next)
+		}
+	}
+}
+
+// Fails every dial and records the addresses.
+type failingDialer struct {
+	addresses chan string
+}
+
+func (d *failingDialer) Dial(network, address string) (net.Conn, error) {
+	d.addresses <- address
+	return nil, errors.New("Unreachable")
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	dialer := &failingDialer{make(chan string, 10)}
+	client := NewClient()
+	client.Dialer = dialer
+	client.Servers, _ = servers.NewServerList(nil)
+	client.address = "72.165.61.174:27017"
+	client.EnableReconnect(ReconnectPolicy{MinDelay: time.Millisecond, MaxAttempts: 2})
+	if !client.connectionLost(nil, errors.New("Connection lost")) {
+		t.Fatal("Expected the client to reconnect")
+	}
+
+	attempts := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-client.Events():
+			switch e := e.(type) {
+			case ReconnectingEvent:
+				attempts++
+				if e.Attempt != attempts || e.Server == "72.165.61.174:27017" {
+					t.Fatalf("Unexpected attempt %+v", e)
+				}
+				continue
+			case FatalError:
+				if !strings.Contains(e.Error(), "Giving up after 2 reconnect attempts") {
+					t.Fatalf("Unexpected error %v", e)
+				}
+			default:
+				continue
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for the FatalError")
+		}
+		break
+	}
+	if attempts != 2 || len(dialer.addresses) != 2 {
+		t.Fatalf("Expected two attempts, got %v events and %v dials", attempts, len(dialer.addresses))
+	}
+	// failed servers are avoided
+	if first, second := <-dialer.addresses, <-dialer.addresses; first == second {
+		t.Fatalf("Expected to try another server after %v failed", first)
+	}
+}
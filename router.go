@@ -0,0 +1,172 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Dispatches incoming packets to the handlers registered for their EMsg.
+// Handlers with a higher priority run first, handlers with the same priority
+// in the order they were registered. It is safe to register and unregister
+// handlers at any time, even from within a handler.
+type Router struct {
+	mutex    sync.RWMutex
+	routes   map[EMsg][]*route // replaced, never modified, so that Route can iterate without the lock
+	fallback []*route          // receive every packet
+	nextId   uint64
+}
+
+type route struct {
+	id       uint64
+	priority int
+	handler  func(*PacketMsg)
+}
+
+// Identifies a registered handler.
+type HandlerToken struct {
+	router *Router
+	eMsg   EMsg
+	all    bool
+	id     uint64
+}
+
+func newRouter() *Router {
+	return &Router{
+		routes: make(map[EMsg][]*route),
+	}
+}
+
+// Registers a handler for the given EMsg with priority 0.
+func (r *Router) Handle(eMsg EMsg, handler func(*PacketMsg)) *HandlerToken {
+	return r.Register(eMsg, 0, handler)
+}
+
+// Registers a handler for the given EMsg.
+func (r *Router) Register(eMsg EMsg, priority int, handler func(*PacketMsg)) *HandlerToken {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextId++
+	r.routes[eMsg] = insertRoute(r.routes[eMsg], &route{r.nextId, priority, handler})
+	return &HandlerToken{router: r, eMsg: eMsg, id: r.nextId}
+}
+
+// Registers a handler for all packets. It runs after the handlers registered for
+// the packet's EMsg.
+func (r *Router) HandleAll(handler func(*PacketMsg)) *HandlerToken {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextId++
+	r.fallback = insertRoute(r.fallback, &route{r.nextId, 0, handler})
+	return &HandlerToken{router: r, all: true, id: r.nextId}
+}
+
+// Returns a copy of routes with the new route inserted after all routes of
+// the same or a higher priority.
+func insertRoute(routes []*route, added *route) []*route {
+	i := sort.Search(len(routes), func(i int) bool {
+		return routes[i].priority < added.priority
+	})
+	result := make([]*route, 0, len(routes)+1)
+	result = append(result, routes[:i]...)
+	result = append(result, added)
+	return append(result, routes[i:]...)
+}
+
+// Registers a handler for the given EMsg with priority 0 which receives the
+// decoded message body. See RegisterBody.
+func (r *Router) HandleBody(eMsg EMsg, handler interface{}) *HandlerToken {
+	return r.RegisterBody(eMsg, 0, handler)
+}
+
+// Registers a handler for the given EMsg which receives the decoded message body.
+// The handler must be a function like
+//
+//	func(packet *PacketMsg, body *CMsgClientLogonResponse)
+//
+// where the body is a pointer to either a protobuf message or a MessageBody
+// like MsgClientChatMsg. A new body is decoded for every packet.
+// It panics if the handler has a different signature.
+func (r *Router) RegisterBody(eMsg EMsg, priority int, handler interface{}) *HandlerToken {
+	return r.Register(eMsg, priority, bodyHandler(handler))
+}
+
+var (
+	packetMsgType   = reflect.TypeOf((*PacketMsg)(nil))
+	protoType       = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	messageBodyType = reflect.TypeOf((*MessageBody)(nil)).Elem()
+)
+
+// Wraps a typed handler in a function decoding the body.
+func bodyHandler(handler interface{}) func(*PacketMsg) {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 0 || t.In(0) != packetMsgType ||
+		t.In(1).Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("steamgo: invalid body handler %v", t))
+	}
+	bodyType := t.In(1)
+	isProto := bodyType.Implements(protoType)
+	if !isProto && !bodyType.Implements(messageBodyType) {
+		panic(fmt.Sprintf("steamgo: %v is neither a protobuf message nor a MessageBody", bodyType))
+	}
+
+	return func(packet *PacketMsg) {
+		body := reflect.New(bodyType.Elem())
+		if isProto {
+			packet.ReadProtoMsg(body.Interface().(proto.Message))
+		} else {
+			packet.ReadClientMsg(body.Interface().(MessageBody))
+		}
+		fn.Call([]reflect.Value{reflect.ValueOf(packet), body})
+	}
+}
+
+// Removes the handler. Unregistering it twice has no effect.
+func (t *HandlerToken) Unregister() {
+	t.router.Unregister(t)
+}
+
+// Removes the handler identified by the token. Unregistering it twice has no effect.
+func (r *Router) Unregister(token *HandlerToken) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if token.all {
+		r.fallback = removeRoute(r.fallback, token.id)
+		return
+	}
+	routes := removeRoute(r.routes[token.eMsg], token.id)
+	if len(routes) == 0 {
+		delete(r.routes, token.eMsg)
+	} else {
+		r.routes[token.eMsg] = routes
+	}
+}
+
+// Returns a copy of routes without the route with the given id.
+func removeRoute(routes []*route, id uint64) []*route {
+	result := make([]*route, 0, len(routes))
+	for _, route := range routes {
+		if route.id != id {
+			result = append(result, route)
+		}
+	}
+	return result
+}
+
+// Calls all handlers for the packet and returns whether there were any.
+func (r *Router) Route(packet *PacketMsg) bool {
+	r.mutex.RLock()
+	routes, fallback := r.routes[packet.EMsg], r.fallback
+	r.mutex.RUnlock()
+	for _, route := range routes {
+		route.handler(packet)
+	}
+	for _, route := range fallback {
+		route.handler(packet)
+	}
+	return len(routes) > 0 || len(fallback) > 0
+}
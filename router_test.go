@@ -0,0 +1,117 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	. "github.com/gamingrobot/steamgo/internal"
+	"reflect"
+	"testing"
+)
+
+// Serializes the message and parses it like a packet received from a CM.
+func packetOf(t *testing.T, msg IMsg) *PacketMsg {
+	buf := new(bytes.Buffer)
+	if err := msg.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	packet, err := NewPacketMsg(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet
+}
+
+func TestRouterPriorities(t *testing.T) {
+	r := newRouter()
+	var calls []string
+	handler := func(name string) func(*PacketMsg) {
+		return func(*PacketMsg) { calls = append(calls, name) }
+	}
+	r.HandleAll(handler("all"))
+	r.Register(EMsg_ClientLogOnResponse, -1, handler("low"))
+	r.Handle(EMsg_ClientLogOnResponse, handler("first"))
+	r.Register(EMsg_ClientLogOnResponse, 10, handler("high"))
+	r.Handle(EMsg_ClientLogOnResponse, handler("second"))
+	r.Handle(EMsg_ClientLoggedOff, handler("other"))
+
+	if !r.Route(&PacketMsg{EMsg: EMsg_ClientLogOnResponse}) {
+		t.Fatal("Expected the packet to be handled")
+	}
+	expected := []string{"high", "first", "second", "low", "all"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("Expected %v, got %v", expected, calls)
+	}
+}
+
+func TestRouterUnregister(t *testing.T) {
+	r := newRouter()
+	var calls []string
+	var second *HandlerToken
+	r.Handle(EMsg_ClientLogOnResponse, func(*PacketMsg) {
+		calls = append(calls, "first")
+		// takes effect for the next packet
+		second.Unregister()
+	})
+	second = r.Handle(EMsg_ClientLogOnResponse, func(*PacketMsg) {
+		calls = append(calls, "second")
+	})
+	all := r.HandleAll(func(*PacketMsg) {
+		calls = append(calls, "all")
+	})
+
+	r.Route(&PacketMsg{EMsg: EMsg_ClientLogOnResponse})
+	r.Route(&PacketMsg{EMsg: EMsg_ClientLogOnResponse})
+	expected := []string{"first", "second", "all", "first", "all"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("Expected %v, got %v", expected, calls)
+	}
+
+	all.Unregister()
+	all.Unregister()
+	if r.Route(&PacketMsg{EMsg: EMsg_ClientLoggedOff}) {
+		t.Fatal("Expected no handlers to be left for the packet")
+	}
+}
+
+func TestRouterHandleBody(t *testing.T) {
+	r := newRouter()
+	var result int32
+	r.HandleBody(EMsg_ClientLogOnResponse, func(packet *PacketMsg, body *CMsgClientLogonResponse) {
+		result = body.GetEresult()
+	})
+	r.Route(packetOf(t, NewClientMsgProtobuf(EMsg_ClientLogOnResponse, &CMsgClientLogonResponse{
+		Eresult: proto.Int32(int32(EResult_AccessDenied)),
+	})))
+	if EResult(result) != EResult_AccessDenied {
+		t.Fatalf("Expected the decoded result, got %v", result)
+	}
+
+	var bans uint32
+	r.HandleBody(EMsg_ClientVACBanStatus, func(packet *PacketMsg, body *MsgClientVACBanStatus) {
+		bans = body.NumBans
+	})
+	vac := NewMsgClientVACBanStatus()
+	vac.NumBans = 3
+	r.Route(packetOf(t, NewClientMsg(vac, nil)))
+	if bans != 3 {
+		t.Fatalf("Expected the decoded MessageBody, got %v bans", bans)
+	}
+}
+
+func TestRouterInvalidBodyHandler(t *testing.T) {
+	for _, handler := range []interface{}{
+		func(*PacketMsg) {},
+		func(*PacketMsg, CMsgClientLogonResponse) {},
+		func(*PacketMsg, *int) {},
+		"not a function",
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %T to be rejected", handler)
+				}
+			}()
+			newRouter().HandleBody(EMsg_ClientLogOnResponse, handler)
+		}()
+	}
+}
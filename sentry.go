@@ -0,0 +1,91 @@
+package steamgo
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Persists the sentry files Steam uses to recognize this machine, so that no
+// Steam Guard code is needed for later logons.
+type SentryStore interface {
+	// Returns the sentry of the given account, or nil if there is none yet.
+	Load(username string) ([]byte, error)
+	Save(username string, data []byte) error
+}
+
+// Keeps sentry files in a directory, one file per account.
+type FileSentryStore struct {
+	Dir string
+}
+
+func NewFileSentryStore(dir string) *FileSentryStore {
+	return &FileSentryStore{Dir: dir}
+}
+
+func (f *FileSentryStore) path(username string) string {
+	return filepath.Join(f.Dir, filepath.Base(username)+".sentry")
+}
+
+func (f *FileSentryStore) Load(username string) ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path(username))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f *FileSentryStore) Save(username string, data []byte) error {
+	err := os.MkdirAll(f.Dir, 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.path(username), data, 0600)
+}
+
+// Keeps sentry files in memory, e.g. for tests or if they are persisted elsewhere.
+type MemorySentryStore struct {
+	mutex    sync.RWMutex
+	sentries map[string][]byte
+}
+
+func NewMemorySentryStore() *MemorySentryStore {
+	return &MemorySentryStore{sentries: make(map[string][]byte)}
+}
+
+func (m *MemorySentryStore) Load(username string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]byte(nil), m.sentries[username]...), nil
+}
+
+func (m *MemorySentryStore) Save(username string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sentries[username] = append([]byte(nil), data...)
+	return nil
+}
+
+// Returns the SHA1 hash of the sentry, or nil if there is none.
+func sentryHash(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	hash := sha1.Sum(data)
+	return hash[:]
+}
+
+// Returns a copy of the sentry with part written at the given offset, growing it
+// if needed. The sentry itself may belong to a SentryStore and isn't modified.
+func writeSentry(sentry []byte, offset int, part []byte) []byte {
+	size := len(sentry)
+	if end := offset + len(part); end > size {
+		size = end
+	}
+	result := make([]byte, size)
+	copy(result, sentry)
+	copy(result[offset:], part)
+	return result
+}
@@ -0,0 +1,125 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"crypto/sha1"
+	. "github.com/gamingrobot/steamgo/internal"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testSentryStore(t *testing.T, store SentryStore) {
+	if sentry, err := store.Load("user"); sentry != nil || err != nil {
+		t.Fatalf("Expected no sentry, got %v, %v", sentry, err)
+	}
+	data := []byte{1, 2, 3}
+	if err := store.Save("user", data); err != nil {
+		t.Fatal(err)
+	}
+	data[0] = 0
+	sentry, err := store.Load("user")
+	if err != nil || !bytes.Equal(sentry, []byte{1, 2, 3}) {
+		t.Fatalf("Expected the saved sentry, got %v, %v", sentry, err)
+	}
+	sentry[1] = 0
+	if sentry, _ := store.Load("user"); !bytes.Equal(sentry, []byte{1, 2, 3}) {
+		t.Fatalf("Expected the store to keep its own copy, got %v", sentry)
+	}
+}
+
+func TestMemorySentryStore(t *testing.T) {
+	testSentryStore(t, NewMemorySentryStore())
+}
+
+func TestFileSentryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sentry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testSentryStore(t, NewFileSentryStore(dir))
+}
+
+func TestWriteSentry(t *testing.T) {
+	sentry := []byte("abcd")
+	tests := []struct {
+		offset   int
+		part     string
+		expected string
+	}{
+		{0, "xy", "xycd"},
+		{2, "xy", "abxy"},
+		{3, "xyz", "abcxyz"},
+		{6, "xy", "abcd\x00\x00xy"},
+	}
+	for _, test := range tests {
+		if result := writeSentry(sentry, test.offset, []byte(test.part)); string(result) != test.expected {
+			t.Errorf("Expected %q after writing %q at %v, got %q", test.expected, test.part, test.offset, result)
+		}
+	}
+	if string(sentry) != "abcd" {
+		t.Fatalf("Expected the sentry to be left alone, got %q", sentry)
+	}
+}
+
+// Sends a part of the sentry and returns the client's response.
+func updateMachineAuth(t *testing.T, client *Client, conn *fakeConnection, offset uint32, part string, cubToWrite uint32) *CMsgClientUpdateMachineAuthResponse {
+	msg := NewClientMsgProtobuf(EMsg_ClientUpdateMachineAuth, &CMsgClientUpdateMachineAuth{
+		Filename:   proto.String("sentry"),
+		Offset:     proto.Uint32(offset),
+		Cubtowrite: proto.Uint32(cubToWrite),
+		Bytes:      []byte(part),
+	})
+	msg.SetSourceJobId(42)
+	client.handlePacket(packetOf(t, msg))
+	response := new(CMsgClientUpdateMachineAuthResponse)
+	if packet := conn.expect(t, EMsg_ClientUpdateMachineAuthResponse); packet.TargetJobId != 42 {
+		t.Fatalf("Expected a response to job 42, got %v", packet.TargetJobId)
+	} else {
+		packet.ReadProtoMsg(response)
+	}
+	return response
+}
+
+func TestUpdateMachineAuth(t *testing.T) {
+	client := NewClient()
+	store := NewMemorySentryStore()
+	client.Auth.SentryStore = store
+	conn := connectFake(client)
+	defer client.Disconnect()
+	client.Auth.LogOn(LogOnDetails{Username: "user", Password: "password"})
+	conn.expect(t, EMsg_ClientLogon)
+
+	// only cubtowrite bytes count
+	response := updateMachineAuth(t, client, conn, 0, "abcdXX", 4)
+	if response.GetEresult() != uint32(EResult_OK) || response.GetCubwrote() != 4 || response.GetFilesize() != 4 || response.GetOffset() != 0 {
+		t.Fatalf("Unexpected response %v", response)
+	}
+	response = updateMachineAuth(t, client, conn, 4, "ef", 2)
+	hash := sha1.Sum([]byte("abcdef"))
+	if response.GetCubwrote() != 2 || response.GetFilesize() != 6 || response.GetOffset() != 4 || !bytes.Equal(response.GetShaFile(), hash[:]) {
+		t.Fatalf("Unexpected response %v", response)
+	}
+	if sentry, _ := store.Load("user"); string(sentry) != "abcdef" {
+		t.Fatalf("Expected the whole sentry to be saved, got %q", sentry)
+	}
+}
+
+func TestUpdateMachineAuthWithoutStore(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+
+	response := updateMachineAuth(t, client, conn, 0, "abcd", 4)
+	hash := sha1.Sum([]byte("abcd"))
+	if response.GetEresult() != uint32(EResult_OK) || !bytes.Equal(response.GetShaFile(), hash[:]) {
+		t.Fatalf("Unexpected response %v", response)
+	}
+	// the first part is gone
+	response = updateMachineAuth(t, client, conn, 4, "ef", 2)
+	if response.GetEresult() != uint32(EResult_Fail) || response.GetCubwrote() != 0 || response.ShaFile != nil {
+		t.Fatalf("Expected the part to be refused, got %v", response)
+	}
+}
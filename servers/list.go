@@ -0,0 +1,322 @@
+package servers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// Assumed latency of servers we never connected to.
+	unknownLatency = 300 * time.Millisecond
+	// Added to a server's score for every consecutive failure.
+	failurePenalty = 10 * time.Second
+	// Failures older than this are forgotten.
+	failureExpiry = time.Hour
+	// Servers are removed from the list after this many consecutive failures.
+	maxFailures = 20
+)
+
+// Changes are saved at most this often, so that connecting never waits for the disk.
+var saveDelay = 10 * time.Second
+
+// A CM server and how well it worked for us.
+type Server struct {
+	Address string
+	// Number of consecutive failed connection attempts
+	Failures    int
+	LastFailure time.Time
+	LastSuccess time.Time
+	// Smoothed time it took to establish a connection, zero if unknown
+	Latency time.Duration
+}
+
+// Lower is better.
+func (s *Server) score(now time.Time) time.Duration {
+	latency := s.Latency
+	if latency == 0 {
+		latency = unknownLatency
+	}
+	if s.Failures > 0 && now.Sub(s.LastFailure) < failureExpiry {
+		latency += time.Duration(s.Failures) * failurePenalty
+	}
+	return latency
+}
+
+// Persists a server list between runs.
+type Store interface {
+	Load() ([]Server, error)
+	Save([]Server) error
+}
+
+// Stores the server list as JSON in a file.
+type FileStore struct {
+	Path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Returns an empty list if the file doesn't exist yet.
+func (f *FileStore) Load() ([]Server, error) {
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Server
+	err = json.Unmarshal(b, &list)
+	return list, err
+}
+
+// Writes to a temporary file of its own first so that the list is never left
+// half-written, even if several processes save at the same time.
+func (f *FileStore) Save(list []Server) error {
+	b, err := json.MarshalIndent(list, "", "\t")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.Path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// A thread-safe list of known CM servers, scored by their failures and latency.
+// It starts out with the built-in CMServers and learns new servers from Steam.
+// Changes are saved to the store, if any, on a best-effort basis a few seconds
+// later; call Flush or Save to save them right away and check for errors.
+type ServerList struct {
+	mutex     sync.RWMutex // guarding servers, dirty and saveTimer
+	servers   map[string]*Server
+	store     Store
+	dirty     bool        // if there are unsaved changes
+	saveTimer *time.Timer // pending save, nil if there is none
+
+	saveMutex sync.Mutex // serializing saves, so that an older list never overwrites a newer one
+}
+
+// Creates a server list backed by the given store, which may be nil.
+// Errors loading the store are returned along with a usable list.
+func NewServerList(store Store) (*ServerList, error) {
+	l := &ServerList{
+		servers: make(map[string]*Server),
+		store:   store,
+	}
+	for _, region := range CMServers {
+		for _, address := range region {
+			l.servers[address] = &Server{Address: address}
+		}
+	}
+	if store == nil {
+		return l, nil
+	}
+	saved, err := store.Load()
+	for i := range saved {
+		server := saved[i]
+		l.servers[server.Address] = &server
+	}
+	return l, err
+}
+
+var (
+	defaultList     *ServerList
+	defaultListOnce sync.Once
+)
+
+// The store of the Default list. It is nil, so that nothing is written to disk
+// unless it is set before the first call to Default, e.g. to NewCacheStore().
+var DefaultStore Store
+
+// Returns the server list shared by all clients that don't set their own.
+// It is only persisted if DefaultStore is set.
+func Default() *ServerList {
+	defaultListOnce.Do(func() {
+		defaultList, _ = NewServerList(DefaultStore)
+	})
+	return defaultList
+}
+
+// Returns a store in the user's cache directory, or the temporary directory if
+// there is none.
+func NewCacheStore() *FileStore {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return NewFileStore(filepath.Join(dir, "steamgo", "servers.json"))
+}
+
+// Adds servers we don't know yet.
+func (l *ServerList) Merge(addresses []string) {
+	l.mutex.Lock()
+	changed := false
+	for _, address := range addresses {
+		if _, ok := l.servers[address]; !ok {
+			l.servers[address] = &Server{Address: address}
+			changed = true
+		}
+	}
+	if changed {
+		l.changed()
+	}
+	l.mutex.Unlock()
+}
+
+// Records a successful connection which took the given time to establish.
+func (l *ServerList) ReportSuccess(address string, latency time.Duration) {
+	l.mutex.Lock()
+	server := l.get(address)
+	server.Failures = 0
+	server.LastSuccess = time.Now()
+	if server.Latency == 0 {
+		server.Latency = latency
+	} else {
+		server.Latency = (server.Latency*3 + latency) / 4
+	}
+	l.changed()
+	l.mutex.Unlock()
+}
+
+// Records a failed connection attempt. Servers that failed too often in a row are
+// removed, unless they are the last ones left.
+func (l *ServerList) ReportFailure(address string) {
+	l.mutex.Lock()
+	server := l.get(address)
+	server.Failures++
+	server.LastFailure = time.Now()
+	if server.Failures >= maxFailures && len(l.servers) > 1 {
+		delete(l.servers, address)
+	}
+	l.changed()
+	l.mutex.Unlock()
+}
+
+// Schedules saving the list. The mutex must be held.
+func (l *ServerList) changed() {
+	if l.store == nil {
+		return
+	}
+	l.dirty = true
+	if l.saveTimer == nil {
+		l.saveTimer = time.AfterFunc(saveDelay, func() {
+			l.Flush()
+		})
+	}
+}
+
+// The mutex must be held.
+func (l *ServerList) get(address string) *Server {
+	server, ok := l.servers[address]
+	if !ok {
+		server = &Server{Address: address}
+		l.servers[address] = server
+	}
+	return server
+}
+
+// Returns the address of the healthiest server, picking randomly among equally
+// good ones. Excluded addresses are only returned if there is nothing else.
+func (l *ServerList) Best(exclude ...string) string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	now := time.Now()
+	var best []string
+	var bestScore time.Duration
+	for address, server := range l.servers {
+		if contains(exclude, address) {
+			continue
+		}
+		score := server.score(now)
+		if len(best) == 0 || score < bestScore {
+			best = []string{address}
+			bestScore = score
+		} else if score == bestScore {
+			best = append(best, address)
+		}
+	}
+	if len(best) == 0 {
+		if len(exclude) > 0 {
+			return exclude[0]
+		}
+		return ""
+	}
+	return best[rand.Intn(len(best))]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a copy of all known servers.
+func (l *ServerList) GetCopy() []Server {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	list := make([]Server, 0, len(l.servers))
+	for _, server := range l.servers {
+		list = append(list, *server)
+	}
+	return list
+}
+
+// Writes the list to the store.
+func (l *ServerList) Save() error {
+	if l.store == nil {
+		return nil
+	}
+	l.saveMutex.Lock()
+	defer l.saveMutex.Unlock()
+	l.mutex.Lock()
+	l.dirty = false
+	if l.saveTimer != nil {
+		l.saveTimer.Stop()
+		l.saveTimer = nil
+	}
+	list := make([]Server, 0, len(l.servers))
+	for _, server := range l.servers {
+		list = append(list, *server)
+	}
+	l.mutex.Unlock()
+	return l.store.Save(list)
+}
+
+// Writes the list to the store if it changed since it was last saved, e.g. before
+// the program exits.
+func (l *ServerList) Flush() error {
+	l.mutex.RLock()
+	dirty := l.dirty
+	l.mutex.RUnlock()
+	if !dirty {
+		return nil
+	}
+	return l.Save()
+}
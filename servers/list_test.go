@@ -0,0 +1,244 @@
+package servers
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Counts saves and keeps the last saved list in memory.
+type memoryStore struct {
+	mutex sync.Mutex
+	saves int
+	list  []Server
+}
+
+func (m *memoryStore) Load() ([]Server, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.list, nil
+}
+
+func (m *memoryStore) Save(list []Server) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.saves++
+	m.list = list
+	return nil
+}
+
+func (m *memoryStore) count() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.saves
+}
+
+// Returns a list of the given servers only.
+func newTestList(store Store, addresses ...string) *ServerList {
+	l := &ServerList{servers: make(map[string]*Server), store: store}
+	for _, address := range addresses {
+		l.servers[address] = &Server{Address: address}
+	}
+	return l
+}
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		server Server
+		score  time.Duration
+	}{
+		{Server{}, unknownLatency},
+		{Server{Latency: 50 * time.Millisecond}, 50 * time.Millisecond},
+		{Server{Latency: 50 * time.Millisecond, Failures: 2, LastFailure: now}, 50*time.Millisecond + 2*failurePenalty},
+		{Server{Failures: 2, LastFailure: now.Add(-failureExpiry)}, unknownLatency},
+	}
+	for _, test := range tests {
+		if score := test.server.score(now); score != test.score {
+			t.Errorf("Expected score %v for %+v, got %v", test.score, test.server, score)
+		}
+	}
+}
+
+func TestReportSuccessSmoothsLatency(t *testing.T) {
+	l := newTestList(nil, "a:1")
+	l.ReportFailure("a:1")
+	l.ReportSuccess("a:1", 100*time.Millisecond)
+	l.ReportSuccess("a:1", 200*time.Millisecond)
+	server := l.GetCopy()[0]
+	if server.Failures != 0 || server.Latency != 125*time.Millisecond {
+		t.Fatalf("Unexpected server %+v", server)
+	}
+}
+
+func TestBest(t *testing.T) {
+	l := newTestList(nil, "a:1", "b:1", "c:1")
+	l.ReportSuccess("a:1", 10*time.Millisecond)
+	l.ReportSuccess("b:1", 20*time.Millisecond)
+	l.ReportFailure("c:1")
+
+	if best := l.Best(); best != "a:1" {
+		t.Fatalf("Expected a:1, got %v", best)
+	}
+	if best := l.Best("a:1"); best != "b:1" {
+		t.Fatalf("Expected b:1 when excluding a:1, got %v", best)
+	}
+	if best := l.Best("a:1", "b:1"); best != "c:1" {
+		t.Fatalf("Expected the failed c:1 as the last option, got %v", best)
+	}
+	if best := l.Best("a:1", "b:1", "c:1"); best != "a:1" {
+		t.Fatalf("Expected the first excluded server if nothing else is left, got %v", best)
+	}
+	if best := newTestList(nil).Best(); best != "" {
+		t.Fatalf("Expected no server, got %v", best)
+	}
+}
+
+func TestFailingServersArePruned(t *testing.T) {
+	l := newTestList(nil, "a:1", "b:1")
+	for i := 0; i < maxFailures-1; i++ {
+		l.ReportFailure("a:1")
+	}
+	if len(l.GetCopy()) != 2 {
+		t.Fatal("Expected a:1 to be kept until it reached maxFailures")
+	}
+	l.ReportFailure("a:1")
+	if list := l.GetCopy(); len(list) != 1 || list[0].Address != "b:1" {
+		t.Fatalf("Expected a:1 to be removed, got %+v", list)
+	}
+
+	// the last server is kept
+	for i := 0; i < maxFailures*2; i++ {
+		l.ReportFailure("b:1")
+	}
+	if best := l.Best(); best != "b:1" {
+		t.Fatalf("Expected b:1 to be kept, got %v", best)
+	}
+
+	// Steam may send it again
+	l.Merge([]string{"a:1"})
+	if len(l.GetCopy()) != 2 {
+		t.Fatal("Expected a:1 to be added again")
+	}
+}
+
+func TestDefaultIsNotPersisted(t *testing.T) {
+	if Default().store != nil {
+		t.Fatal("Expected the default list to have no store")
+	}
+	if path := NewCacheStore().Path; filepath.Base(path) != "servers.json" {
+		t.Fatalf("Unexpected cache path %v", path)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "cache", "servers.json"))
+	list, err := store.Load()
+	if err != nil || list != nil {
+		t.Fatalf("Expected an empty list before saving, got %v, %v", list, err)
+	}
+
+	saved := []Server{
+		{Address: "a:1", Latency: 10 * time.Millisecond, LastSuccess: time.Unix(1500000000, 0).UTC()},
+		{Address: "b:1", Failures: 3, LastFailure: time.Unix(1500000100, 0).UTC()},
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Fatalf("Expected %+v, got %+v", saved, loaded)
+	}
+
+	// learned servers are added to the built-in ones
+	l, err := NewServerList(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, server := range l.GetCopy() {
+		if server.Address == "a:1" && server.Latency == 10*time.Millisecond ||
+			server.Address == "b:1" && server.Failures == 3 {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatal("Expected the saved servers in the list")
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(filepath.Join(dir, "servers.json"))
+	l := newTestList(store, "a:1")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				l.ReportSuccess("a:1", time.Millisecond)
+				if err := l.Save(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0].Address != "a:1" {
+		t.Fatalf("Unexpected list %+v", loaded)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("Expected no temporary files to be left, got %v", files)
+	}
+}
+
+func TestSavesAreDebounced(t *testing.T) {
+	defer func(delay time.Duration) {
+		saveDelay = delay
+	}(saveDelay)
+	saveDelay = 200 * time.Millisecond
+
+	store := new(memoryStore)
+	l := newTestList(store, "a:1")
+	for i := 0; i < 10; i++ {
+		l.ReportSuccess("a:1", time.Millisecond)
+		l.ReportFailure("b:1")
+		l.Merge([]string{"c:1"})
+	}
+	if saves := store.count(); saves != 0 {
+		t.Fatalf("Expected no synchronous saves, got %v", saves)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if saves := store.count(); saves != 1 {
+		t.Fatalf("Expected one delayed save, got %v", saves)
+	}
+	if list, _ := store.Load(); len(list) != 3 {
+		t.Fatalf("Expected all changes to be saved, got %+v", list)
+	}
+
+	// nothing changed since
+	if err := l.Flush(); err != nil || store.count() != 1 {
+		t.Fatalf("Expected Flush to skip saving, got %v saves, %v", store.count(), err)
+	}
+	l.ReportFailure("a:1")
+	if err := l.Flush(); err != nil || store.count() != 2 {
+		t.Fatalf("Expected Flush to save the change, got %v saves, %v", store.count(), err)
+	}
+}
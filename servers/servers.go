@@ -2,7 +2,6 @@ package servers
 
 import (
 	"math/rand"
-	"time"
 )
 
 var CMServers = [][]string{
@@ -58,17 +57,14 @@ var CMServers = [][]string{
 }
 
 func GetRandomCM() string {
-	rng := rand.New(rand.NewSource(time.Now().Unix()))
-	servers := append(CMServers[0], CMServers[1]...)
-	return servers[rng.Int31n(int32(len(servers)))]
+	servers := append(append([]string(nil), CMServers[0]...), CMServers[1]...)
+	return servers[rand.Intn(len(servers))]
 }
 
 func GetRandomNorthAmericaCM() string {
-	rng := rand.New(rand.NewSource(time.Now().Unix()))
-	return CMServers[0][rng.Int31n(int32(len(CMServers[0])))]
+	return CMServers[0][rand.Intn(len(CMServers[0]))]
 }
 
 func GetRandomEuropeCM() string {
-	rng := rand.New(rand.NewSource(time.Now().Unix()))
-	return CMServers[1][rng.Int31n(int32(len(CMServers[1])))]
+	return CMServers[1][rand.Intn(len(CMServers[1]))]
 }
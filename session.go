@@ -0,0 +1,247 @@
+package steamgo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gamingrobot/steamgo/connection"
+	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
+	"time"
+)
+
+// Used if Steam doesn't tell us how often to send heartbeats.
+const defaultHeartbeatInterval = 9 * time.Second
+
+// One connection to a CM and the goroutines serving it. Every connection gets a new
+// session, so the goroutines of an old connection never touch the next one.
+//
+// A session ends when the client detaches it, which closes the closed channel. Its
+// channels are never closed otherwise, so writing to an ended session can't panic.
+type session struct {
+	client   *Client
+	conn     connection.Connection
+	address  string
+	start    time.Time // when we started dialing
+	recorder connection.Recorder
+
+	writeChan chan IMsg
+	heartbeat chan time.Duration // changes the interval of heartbeatLoop
+	closed    chan struct{}      // closed when the session was detached from the client
+	closeOnce sync.Once
+	failOnce  sync.Once     // the read and write errors of a connection usually come together
+	writeDone chan struct{} // closed when writeLoop returned
+
+	connectResult chan error // receives the result of the encryption handshake
+
+	mutex          sync.Mutex // guarding connected, secure and tempSessionKey
+	connected      bool       // if the handshake finished
+	secure         bool       // if messages are encrypted from now on
+	tempSessionKey []byte
+}
+
+func newSession(client *Client, conn connection.Connection, address string, start time.Time) *session {
+	return &session{
+		client:        client,
+		conn:          conn,
+		address:       address,
+		start:         start,
+		recorder:      client.Recorder,
+		writeChan:     make(chan IMsg, 5),
+		heartbeat:     make(chan time.Duration),
+		closed:        make(chan struct{}),
+		writeDone:     make(chan struct{}),
+		connectResult: make(chan error, 1),
+	}
+}
+
+// Starts the goroutines of the session.
+func (s *session) run() {
+	s.client.goroutines.Add(3)
+	go s.readLoop()
+	go s.writeLoop()
+	go s.heartbeatLoop()
+}
+
+// Ends the session. The connection stays open until it is closed separately.
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+	})
+}
+
+// Queues the message unless the session ended. Returns false if it did.
+func (s *session) write(msg IMsg) bool {
+	select {
+	case s.writeChan <- msg:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+// Sends heartbeats in the given interval, or the default one if it isn't positive,
+// until the session ends.
+func (s *session) setHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	select {
+	case s.heartbeat <- interval:
+	case <-s.closed:
+	}
+}
+
+// Reports the result of the encryption handshake to a waiting ConnectContext and
+// to the server list. Only the first result counts.
+func (s *session) finishConnect(err error) {
+	if !s.setConnected() {
+		return
+	}
+	s.connectResult <- err
+	s.client.reportServer(s.address, err, time.Since(s.start))
+}
+
+// Tells a waiting ConnectContext that the session ended before the handshake
+// finished, without blaming the server.
+func (s *session) abortConnect() {
+	if s.setConnected() {
+		s.connectResult <- ErrConnectAborted
+	}
+}
+
+// Allows writing messages other than those of the handshake.
+func (s *session) setSecure() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.secure = true
+}
+
+func (s *session) isSecure() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.secure
+}
+
+// Returns false if the handshake already finished.
+func (s *session) setConnected() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.connected {
+		return false
+	}
+	s.connected = true
+	return true
+}
+
+func (s *session) readLoop() {
+	defer s.client.goroutines.Done()
+	for {
+		packet, err := s.conn.Read()
+		if err != nil {
+			s.client.connectionFailed(s, fmt.Errorf("Error reading from the connection: %v", err))
+			return
+		}
+		// packets arriving after the session ended are dropped, but we keep reading
+		// until the connection is closed
+		if s.client.isCurrent(s) {
+			s.record(&connection.CapturedPacket{
+				Direction:   connection.Inbound,
+				EMsg:        packet.EMsg,
+				TargetJobId: packet.TargetJobId,
+				SourceJobId: packet.SourceJobId,
+				Data:        packet.Data,
+			})
+			s.client.handlePacket(packet)
+		}
+	}
+}
+
+// Writes the queued messages until the session ends, then the messages that were
+// still queued at that time.
+func (s *session) writeLoop() {
+	defer s.client.goroutines.Done()
+	defer close(s.writeDone)
+	buf := new(bytes.Buffer)
+	for {
+		select {
+		case msg := <-s.writeChan:
+			if !s.send(buf, msg) {
+				return
+			}
+		case <-s.closed:
+			for {
+				select {
+				case msg := <-s.writeChan:
+					if !s.send(buf, msg) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Returns false if the connection failed.
+func (s *session) send(buf *bytes.Buffer, msg IMsg) bool {
+	buf.Reset()
+	err := msg.Serialize(buf)
+	if err != nil {
+		s.client.Errorf("Error serializing message %v: %v", msg, err)
+		return true
+	}
+	if s.recorder != nil {
+		// buf is reused for the next message
+		s.record(&connection.CapturedPacket{
+			Direction:   connection.Outbound,
+			EMsg:        msg.GetMsgType(),
+			TargetJobId: msg.GetTargetJobId(),
+			SourceJobId: msg.GetSourceJobId(),
+			Data:        append([]byte(nil), buf.Bytes()...),
+		})
+	}
+	err = s.conn.Write(buf.Bytes())
+	if err != nil {
+		s.client.connectionFailed(s, fmt.Errorf("Error writing message %v: %v", msg, err))
+		return false
+	}
+	return true
+}
+
+// Passes the packet to the recorder, if there is one.
+func (s *session) record(packet *connection.CapturedPacket) {
+	if s.recorder == nil {
+		return
+	}
+	packet.Time = time.Now()
+	err := s.recorder.Record(packet)
+	if err != nil {
+		s.client.Errorf("Error recording packet %v: %v", packet.EMsg, err)
+	}
+}
+
+func (s *session) heartbeatLoop() {
+	defer s.client.goroutines.Done()
+	var ticker *time.Ticker
+	var ticks <-chan time.Time // nil until the first interval is set
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+	for {
+		select {
+		case interval := <-s.heartbeat:
+			if ticker != nil {
+				ticker.Stop()
+			}
+			ticker = time.NewTicker(interval)
+			ticks = ticker.C
+		case <-ticks:
+			s.client.writeTo(s, NewClientMsgProtobuf(EMsg_ClientHeartBeat, new(CMsgClientHeartBeat)))
+		case <-s.closed:
+			return
+		}
+	}
+}
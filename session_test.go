@@ -0,0 +1,194 @@
+package steamgo_test
+
+import (
+	"context"
+	"github.com/gamingrobot/steamgo"
+	"github.com/gamingrobot/steamgo/connection"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/testing/fakecm"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stressIterations() int {
+	if testing.Short() {
+		return 50
+	}
+	return 500
+}
+
+// Calls Write and Connected in a loop until the returned function is called.
+func hammer(client *steamgo.Client, goroutines int) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				client.Write(NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+				client.Connected()
+				runtime.Gosched()
+			}
+		}()
+	}
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+func TestConnectDisconnectStress(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := newClient()
+	stop := hammer(client, 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for i := 0; i < stressIterations(); i++ {
+		connectClient(t, client, server)
+		switch i % 3 {
+		case 0:
+			client.Disconnect()
+		case 1:
+			if err := client.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+		case 2:
+			// the next connect replaces the session whose connection failed
+			server.DisconnectClients()
+		}
+		if err := server.WaitDisconnected(ctx); err != nil {
+			t.Fatalf("Connection %v wasn't closed: %v", i, err)
+		}
+	}
+
+	stop()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.Connected() {
+		t.Fatal("Expected the client to be disconnected")
+	}
+}
+
+func TestConcurrentConnectStress(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := newClient()
+	stop := hammer(client, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var connects sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		connects.Add(1)
+		go func() {
+			defer connects.Done()
+			for j := 0; j < stressIterations()/8; j++ {
+				connectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				err := client.ConnectContext(connectCtx, server.Addr())
+				cancel()
+				if err != nil && err != steamgo.ErrConnectAborted {
+					t.Error(err)
+					return
+				}
+				if j%2 == 0 {
+					client.Disconnect()
+				}
+			}
+		}()
+	}
+	connects.Wait()
+
+	stop()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.WaitDisconnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteAfterConnectionFailed(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	client := connect(t, server)
+	server.DisconnectClients()
+
+	// more messages than fit in the queue must not block
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			client.Write(NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked after the connection failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Keeps every recorded packet.
+type keepingRecorder struct {
+	mutex   sync.Mutex
+	packets []*connection.CapturedPacket
+}
+
+func (r *keepingRecorder) Record(packet *connection.CapturedPacket) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packets = append(r.packets, packet)
+	return nil
+}
+
+func TestRecordedPacketsArentReused(t *testing.T) {
+	server := startServer(t, fakecm.NewServer())
+	defer server.Close()
+	recorder := new(keepingRecorder)
+	client := newClient()
+	client.Recorder = recorder
+	connectClient(t, client, server)
+	defer client.Close(context.Background())
+
+	client.Write(NewClientMsgProtobuf(EMsg_ClientGamesPlayed, new(CMsgClientGamesPlayed)))
+	client.Write(NewClientMsgProtobuf(EMsg_ClientChangeStatus, new(CMsgClientChangeStatus)))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := server.WaitFor(ctx, EMsg_ClientChangeStatus); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	outbound := 0
+	for _, p := range recorder.packets {
+		if p.Direction != connection.Outbound {
+			continue
+		}
+		outbound++
+		packet, err := NewPacketMsg(p.Data)
+		if err != nil || packet.EMsg != p.EMsg {
+			t.Fatalf("Expected the data of %v, got %v, %v", p.EMsg, packet, err)
+		}
+	}
+	if outbound < 3 {
+		t.Fatalf("Expected the handshake and both messages, got %v outbound packets", outbound)
+	}
+}
@@ -209,35 +209,20 @@ func (s *Social) UnbanChatMember(room SteamId, user SteamId) {
 	}, make([]byte, 0)))
 }
 
-func (s *Social) HandlePacket(packet *PacketMsg) {
-	switch packet.EMsg {
-	case EMsg_ClientPersonaState:
-		s.handlePersonaState(packet)
-	case EMsg_ClientClanState:
-		s.handleClanState(packet)
-	case EMsg_ClientFriendsList:
-		s.handleFriendsList(packet)
-	case EMsg_ClientFriendMsgIncoming:
-		s.handleFriendMsg(packet)
-	case EMsg_ClientAccountInfo:
-		s.handleAccountInfo(packet)
-	case EMsg_ClientAddFriendResponse:
-		s.handleFriendResponse(packet)
-	case EMsg_ClientChatEnter:
-		s.handleChatEnter(packet)
-	case EMsg_ClientChatMsg:
-		s.handleChatMsg(packet)
-	case EMsg_ClientChatMemberInfo:
-		s.handleChatMemberInfo(packet)
-	case EMsg_ClientChatActionResult:
-		s.handleChatActionResult(packet)
-	case EMsg_ClientChatInvite:
-		s.handleChatInvite(packet)
-	case EMsg_ClientSetIgnoreFriendResponse:
-		s.handleIgnoreFriendResponse(packet)
-	case EMsg_ClientFriendProfileInfoResponse:
-		s.handleProfileInfoResponse(packet)
-	}
+func (s *Social) registerHandlers(r *Router) {
+	r.Handle(EMsg_ClientPersonaState, s.handlePersonaState)
+	r.Handle(EMsg_ClientClanState, s.handleClanState)
+	r.Handle(EMsg_ClientFriendsList, s.handleFriendsList)
+	r.Handle(EMsg_ClientFriendMsgIncoming, s.handleFriendMsg)
+	r.Handle(EMsg_ClientAccountInfo, s.handleAccountInfo)
+	r.Handle(EMsg_ClientAddFriendResponse, s.handleFriendResponse)
+	r.Handle(EMsg_ClientChatEnter, s.handleChatEnter)
+	r.Handle(EMsg_ClientChatMsg, s.handleChatMsg)
+	r.Handle(EMsg_ClientChatMemberInfo, s.handleChatMemberInfo)
+	r.Handle(EMsg_ClientChatActionResult, s.handleChatActionResult)
+	r.Handle(EMsg_ClientChatInvite, s.handleChatInvite)
+	r.Handle(EMsg_ClientSetIgnoreFriendResponse, s.handleIgnoreFriendResponse)
+	r.Handle(EMsg_ClientFriendProfileInfoResponse, s.handleProfileInfoResponse)
 }
 
 func (s *Social) handleAccountInfo(packet *PacketMsg) {
@@ -24,5 +24,15 @@ received the LoggedOnEvent, you should set your persona state to online to recei
 		}
 	}
 
+Instead of reconnecting manually, you can call client.EnableReconnect(steamgo.DefaultReconnectPolicy)
+before connecting. The client then switches to another server and logs on again by itself when the
+connection is lost, emitting a ReconnectingEvent for every attempt.
+
+Instead of polling Events(), independent parts of a program can subscribe to the events they need.
+Every subscription has its own queue, so a slow subscriber doesn't hold up the others:
+
+	client.Subscribe(func(e steamgo.ChatMsgEvent) {
+		log.Printf("%v: %v", e.ChatterId, e.Message)
+	})
 */
 package steamgo
@@ -0,0 +1,171 @@
+package steamgo
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"time"
+)
+
+// Supplies Steam Guard codes when Steam asks for them during a logon, so that
+// bots can get them from a mailbox or an authenticator without user interaction.
+// The methods are called on a goroutine of their own and may block.
+type CredentialProvider interface {
+	// Returns the code Steam sent to the account's email address at the given domain.
+	// If previousIncorrect is true, the last code was rejected.
+	EmailCode(emailDomain string, previousIncorrect bool) (string, error)
+	// Returns the current code of the account's mobile authenticator.
+	// If previousIncorrect is true, the last code was rejected.
+	TwoFactorCode(previousIncorrect bool) (string, error)
+}
+
+// Implemented by providers generating time based codes, like guard.Authenticator,
+// to align them to Steam's time.
+type serverTimeSetter interface {
+	SetServerTime(serverTime time.Time)
+}
+
+// Passes the server time of a logon response to the credential providers.
+func (a *Auth) setServerTime(serverTime uint32) {
+	if serverTime == 0 {
+		return
+	}
+	for _, provider := range []CredentialProvider{a.CredentialProvider, a.client.Authentication.CredentialProvider} {
+		if setter, ok := provider.(serverTimeSetter); ok {
+			setter.SetServerTime(time.Unix(int64(serverTime), 0))
+		}
+	}
+}
+
+// Wrapped in a SteamGuardError if Steam asks for a code on a connection passed to
+// Client.ConnectWith, which can't be opened again to retry the logon.
+var ErrCannotReconnect = errors.New("Can't reconnect to retry the logon")
+
+// The number of codes tried before giving up.
+const maxSteamGuardAttempts = 3
+
+// Wrapped in the FatalError emitted if Steam requires a Steam Guard code and
+// no CredentialProvider is set, or the provider failed.
+type SteamGuardError struct {
+	Result EResult
+	// Whether a code from the mobile authenticator is required instead of an email code
+	TwoFactor bool
+	// The domain of the email address the code was sent to
+	EmailDomain string
+	// The error returned by the CredentialProvider, if any
+	Err error
+}
+
+func (e *SteamGuardError) Error() string {
+	kind := "email code"
+	if e.TwoFactor {
+		kind = "two-factor code"
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("Steam Guard %v required (%v): %v", kind, e.Result, e.Err)
+	}
+	return fmt.Sprintf("Steam Guard %v required (%v)", kind, e.Result)
+}
+
+// Whether the logon result asks for a Steam Guard code.
+func isSteamGuardResult(result EResult) bool {
+	switch result {
+	case EResult_AccountLogonDenied, EResult_InvalidLoginAuthCode, EResult_ExpiredLoginAuthCode,
+		EResult_AccountLoginDeniedNeedTwoFactor, EResult_TwoFactorCodeMismatch:
+		return true
+	}
+	return false
+}
+
+// Asks the CredentialProvider for a code and logs on again with it.
+func (a *Auth) handleSteamGuard(result EResult, emailDomain string) {
+	guardErr := &SteamGuardError{
+		Result:      result,
+		TwoFactor:   result == EResult_AccountLoginDeniedNeedTwoFactor || result == EResult_TwoFactorCodeMismatch,
+		EmailDomain: emailDomain,
+	}
+
+	a.mutex.Lock()
+	a.guardAttempts++
+	attempts := a.guardAttempts
+	a.mutex.Unlock()
+	if a.CredentialProvider == nil || attempts > maxSteamGuardAttempts {
+		a.client.fatal(guardErr)
+		return
+	}
+
+	a.client.mutex.RLock()
+	address := a.client.address
+	a.client.mutex.RUnlock()
+	if address == "" {
+		guardErr.Err = ErrCannotReconnect
+		a.client.fatal(guardErr)
+		return
+	}
+
+	// Steam closes the connection anyway, and the provider may take a while.
+	// Disconnect, Close and DisableReconnect abort the retry.
+	a.client.endSession(nil)
+	ctx := a.client.backgroundContext()
+	stop := a.client.reconnectStopChan()
+	aborted := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return ctx.Err() != nil
+		}
+	}
+	a.client.goroutines.Add(1)
+	go func() {
+		defer a.client.goroutines.Done()
+		var code string
+		var err error
+		if guardErr.TwoFactor {
+			code, err = a.CredentialProvider.TwoFactorCode(result == EResult_TwoFactorCodeMismatch)
+		} else {
+			code, err = a.CredentialProvider.EmailCode(emailDomain, result != EResult_AccountLogonDenied)
+		}
+		if aborted() {
+			return
+		}
+		if err != nil {
+			guardErr.Err = err
+			a.client.fatal(guardErr)
+			return
+		}
+
+		a.mutex.Lock()
+		if a.details == nil {
+			a.mutex.Unlock()
+			return
+		}
+		details := *a.details
+		if guardErr.TwoFactor {
+			details.TwoFactorCode = code
+		} else {
+			details.AuthCode = code
+		}
+		// picked up by LogOn calls without a code, e.g. after reconnecting
+		a.details = &details
+		a.mutex.Unlock()
+
+		if !a.client.Connected() {
+			attemptCtx, cancel := attemptContext(ctx, stop)
+			err = a.client.ConnectContext(attemptCtx, address)
+			cancel()
+			if aborted() {
+				if err == nil {
+					// we connected right before being aborted
+					a.client.endSession(nil)
+				}
+				return
+			}
+			if err != nil {
+				a.client.Fatalf("Error reconnecting to retry the logon: %v", err)
+				return
+			}
+		}
+		a.LogOn(details)
+	}()
+}
@@ -0,0 +1,203 @@
+package steamgo_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/gamingrobot/steamgo"
+	"github.com/gamingrobot/steamgo/connection"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/testing/fakecm"
+	"testing"
+	"time"
+)
+
+// Returns the email code once it was released.
+type blockingProvider struct {
+	asked   chan struct{}
+	release chan struct{}
+}
+
+func newBlockingProvider() *blockingProvider {
+	return &blockingProvider{asked: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (p *blockingProvider) EmailCode(emailDomain string, previousIncorrect bool) (string, error) {
+	p.asked <- struct{}{}
+	<-p.release
+	return "ABCDE", nil
+}
+
+func (p *blockingProvider) TwoFactorCode(previousIncorrect bool) (string, error) {
+	return p.EmailCode("", previousIncorrect)
+}
+
+func steamGuardServer(t *testing.T) *fakecm.Server {
+	server := fakecm.NewServer()
+	server.LogOnResults = []EResult{EResult_AccountLogonDenied}
+	return startServer(t, server)
+}
+
+func TestSteamGuardRetry(t *testing.T) {
+	server := steamGuardServer(t)
+	defer server.Close()
+	provider := newBlockingProvider()
+	close(provider.release)
+	client := newClient()
+	client.Auth.CredentialProvider = provider
+	connectClient(t, client, server)
+	defer client.Close(context.Background())
+
+	logOn(t, client)
+	var codes []string
+	for _, packet := range server.Received() {
+		if packet.EMsg == EMsg_ClientLogon {
+			body := new(CMsgClientLogon)
+			packet.ReadProtoMsg(body)
+			codes = append(codes, body.GetAuthCode())
+		}
+	}
+	if len(codes) != 2 || codes[0] != "" || codes[1] != "ABCDE" {
+		t.Fatalf("Expected a logon without and one with the code, got %q", codes)
+	}
+}
+
+func TestLogOnWithTwoFactorCode(t *testing.T) {
+	server := fakecm.NewServer()
+	// no response, so the logon stays pending
+	server.Ignore = []EMsg{EMsg_ClientLogon}
+	startServer(t, server)
+	defer server.Close()
+	client := connect(t, server)
+	defer client.Close(context.Background())
+
+	details := steamgo.LogOnDetails{Username: "user", Password: "password", TwoFactorCode: "ABCDE"}
+	client.Auth.LogOn(details)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	packet, err := server.WaitFor(ctx, EMsg_ClientLogon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := new(CMsgClientLogon)
+	packet.ReadProtoMsg(body)
+	if body.GetTwoFactorCode() != "ABCDE" || body.GetAuthCode() != "" {
+		t.Fatalf("Expected the two-factor code, got %v", body)
+	}
+
+	// ignored while waiting for the response
+	client.Auth.LogOn(details)
+	time.Sleep(50 * time.Millisecond)
+	if logOns := count(server, EMsg_ClientLogon); logOns != 1 {
+		t.Fatalf("Expected one logon, got %v", logOns)
+	}
+}
+
+func TestSteamGuardWithoutProvider(t *testing.T) {
+	server := fakecm.NewServer()
+	server.LogOnResult = EResult_AccountLoginDeniedNeedTwoFactor
+	startServer(t, server)
+	defer server.Close()
+	client := newClient()
+	errs := make(chan error, 1)
+	client.Subscribe(func(err steamgo.FatalError) {
+		errs <- err
+	})
+	connectClient(t, client, server)
+	defer client.Close(context.Background())
+
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	select {
+	case err := <-errs:
+		guardErr, ok := err.(*steamgo.SteamGuardError)
+		if !ok || !guardErr.TwoFactor || guardErr.Result != EResult_AccountLoginDeniedNeedTwoFactor {
+			t.Fatalf("Expected a SteamGuardError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the FatalError")
+	}
+	waitDisconnected(t, server)
+}
+
+func TestSteamGuardRetryAbortedByDisconnect(t *testing.T) {
+	server := steamGuardServer(t)
+	defer server.Close()
+	provider := newBlockingProvider()
+	client := newClient()
+	client.Auth.CredentialProvider = provider
+	connectClient(t, client, server)
+
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	select {
+	case <-provider.asked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the provider to be asked")
+	}
+	client.Disconnect()
+	close(provider.release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.Connected() {
+		t.Fatal("Expected the retry to be aborted")
+	}
+	waitDisconnected(t, server)
+	if logOns := count(server, EMsg_ClientLogon); logOns != 1 {
+		t.Fatalf("Expected one logon, got %v", logOns)
+	}
+}
+
+func TestSteamGuardWithoutAddress(t *testing.T) {
+	client := newClient()
+	client.Auth.CredentialProvider = newBlockingProvider()
+	errs := make(chan error, 1)
+	client.Subscribe(func(err steamgo.FatalError) {
+		errs <- err
+	})
+
+	server := steamGuardServer(t)
+	defer server.Close()
+	// a replay of a session asking for a code, which can't be reconnected
+	conn, err := recordSteamGuard(t, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.ConnectWith(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(context.Background())
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+
+	select {
+	case err := <-errs:
+		guardErr, ok := err.(*steamgo.SteamGuardError)
+		if !ok || guardErr.Err != steamgo.ErrCannotReconnect {
+			t.Fatalf("Expected ErrCannotReconnect, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the FatalError")
+	}
+}
+
+// Records a session whose logon asks for a Steam Guard code and returns a replay.
+func recordSteamGuard(t *testing.T, server *fakecm.Server) (connection.Connection, error) {
+	capture := new(bytes.Buffer)
+	recorder, err := connection.NewCaptureWriter(capture)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient()
+	client.Recorder = recorder
+	connectClient(t, client, server)
+	// without a CredentialProvider, the client gives up
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	waitDisconnected(t, server)
+	if err := client.Close(context.Background()); err != nil {
+		return nil, err
+	}
+	return connection.NewReplayConnection(bytes.NewReader(capture.Bytes()))
+}
@@ -0,0 +1,427 @@
+// A fake Steam CM server for testing clients without the Steam network.
+//
+// The server listens on the loopback interface and performs the ChannelEncrypt
+// handshake with a test key, which replaces Steam's public key in the keys package
+// for the whole process. It accepts every logon, hands out app ownership tickets
+// and encrypted app tickets, acknowledges every auth list and sends the fixtures of its Script. Everything clients send after the handshake is recorded for assertions.
+package fakecm
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gamingrobot/steamgo/connection"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/keys"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"hash/crc32"
+	"net"
+	"sync"
+	"time"
+)
+
+// The SteamId of clients that log on to an account, unless the Server's is set.
+var DefaultSteamId = NewIdAdv(12345, 1, int32(EUniverse_Public), EAccountType_Individual)
+
+// Messages sent to a client after it sent a message of a type.
+type Fixture struct {
+	// The type of the message that triggers the fixture. For logons, the fixture
+	// follows the logon response. If EMsg_Invalid, it is sent right after the handshake.
+	After EMsg
+	// Sent in order. Messages may be shared by several clients and are never modified.
+	Messages []IMsg
+}
+
+type Server struct {
+	// The result of every logon. Zero means EResult_OK.
+	LogOnResult EResult
+	// The results of the first logons of all clients, e.g. to ask for a Steam Guard
+	// code once. Later logons get LogOnResult.
+	LogOnResults []EResult
+	// The SteamId of clients that logged on to an account. If zero, DefaultSteamId is used.
+	SteamId SteamId
+	// The fixtures each client receives, in order. A fixture is sent once its
+	// message arrived after the previous fixture was sent.
+	Script []Fixture
+	// Messages the server doesn't answer, e.g. EMsg_ClientLogOff to test timeouts.
+	//
+	// The fields of a Server must not be changed after Start.
+	Ignore []EMsg
+
+	listener net.Listener
+	key      *rsa.PrivateKey
+
+	mutex     sync.Mutex // guarding conns, received, changed, sessionId and logOns
+	conns     map[*conn]bool
+	received  []*PacketMsg
+	changed   chan struct{} // closed and replaced whenever a packet arrives or a client disconnects
+	sessionId int32
+	logOns    int
+	wg        sync.WaitGroup
+}
+
+var (
+	keyOnce sync.Once
+	testKey *rsa.PrivateKey
+)
+
+// Returns the key shared by all fake servers, which clients use from then on.
+func privateKey() *rsa.PrivateKey {
+	keyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			panic(err)
+		}
+		testKey = key
+		keys.SetPublicKey(EUniverse_Public, &key.PublicKey)
+	})
+	return testKey
+}
+
+// Creates a server that accepts connections once it was started.
+func NewServer() *Server {
+	return &Server{
+		key:     privateKey(),
+		conns:   make(map[*conn]bool),
+		changed: make(chan struct{}),
+	}
+}
+
+// Starts listening on a random port of the loopback interface.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return nil
+}
+
+// Returns the address to connect to, e.g. with Client.ConnectContext. The server
+// must have been started.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stops listening, closes all connections and waits until they are closed.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.DisconnectClients()
+	s.wg.Wait()
+	return err
+}
+
+// Closes the connections to all clients, e.g. to test reconnecting.
+func (s *Server) DisconnectClients() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+}
+
+// Sends the message to all clients that finished the handshake.
+func (s *Server) Send(msg IMsg) {
+	s.mutex.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mutex.Unlock()
+	for _, c := range conns {
+		c.send(msg)
+	}
+}
+
+// Returns everything clients sent after the handshake, in order of arrival.
+func (s *Server) Received() []*PacketMsg {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*PacketMsg(nil), s.received...)
+}
+
+// Waits until a client sent a message of the given type and returns the first one.
+func (s *Server) WaitFor(ctx context.Context, eMsg EMsg) (*PacketMsg, error) {
+	for {
+		s.mutex.Lock()
+		for _, packet := range s.received {
+			if packet.EMsg == eMsg {
+				s.mutex.Unlock()
+				return packet, nil
+			}
+		}
+		changed := s.changed
+		s.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("Waiting for %v: %v", eMsg, ctx.Err())
+		}
+	}
+}
+
+// Waits until all clients that finished the handshake disconnected and everything
+// they sent was recorded.
+func (s *Server) WaitDisconnected(ctx context.Context) error {
+	for {
+		s.mutex.Lock()
+		if len(s.conns) == 0 {
+			s.mutex.Unlock()
+			return nil
+		}
+		changed := s.changed
+		s.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("Waiting for clients to disconnect: %v", ctx.Err())
+		}
+	}
+}
+
+func (s *Server) record(packet *PacketMsg) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.received = append(s.received, packet)
+	s.notify()
+}
+
+// Wakes up all waiting goroutines. The mutex must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Returns the SteamId of clients logged on to an account.
+func (s *Server) accountSteamId() SteamId {
+	if s.SteamId == 0 {
+		return DefaultSteamId
+	}
+	return s.SteamId
+}
+
+func (s *Server) logOnResult() EResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.logOns++
+	if s.logOns <= len(s.LogOnResults) {
+		return s.LogOnResults[s.logOns-1]
+	}
+	return s.LogOnResult
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go s.serve(connection.NewTCPConnection(netConn))
+	}
+}
+
+// The server side of the connection to a client.
+type conn struct {
+	server *Server
+	conn   connection.Connection
+	script []Fixture // not sent yet
+
+	writeMutex sync.Mutex // connection.Connection doesn't support concurrent writes
+	sessionId  int32
+}
+
+func (s *Server) serve(netConn connection.Connection) {
+	defer s.wg.Done()
+	defer netConn.Close()
+
+	c := &conn{server: s, conn: netConn, script: s.Script}
+	if err := c.handshake(); err != nil {
+		return
+	}
+	s.mutex.Lock()
+	s.sessionId++
+	c.sessionId = s.sessionId
+	s.conns[c] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.notify()
+		s.mutex.Unlock()
+	}()
+
+	c.runScript(EMsg_Invalid)
+	for {
+		packet, err := c.conn.Read()
+		if err != nil {
+			return
+		}
+		s.record(packet)
+		c.handlePacket(packet)
+		c.runScript(packet.EMsg)
+	}
+}
+
+func (c *conn) send(msg IMsg) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	buf := new(bytes.Buffer)
+	err := msg.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return c.conn.Write(buf.Bytes())
+}
+
+var ErrHandshake = errors.New("Invalid ChannelEncryptResponse")
+
+func (c *conn) handshake() error {
+	request := NewMsgChannelEncryptRequest()
+	request.Universe = EUniverse_Public
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	err := c.send(NewMsg(request, challenge))
+	if err != nil {
+		return err
+	}
+
+	packet, err := c.conn.Read()
+	if err != nil {
+		return err
+	}
+	if packet.EMsg != EMsg_ChannelEncryptResponse {
+		return ErrHandshake
+	}
+	body := NewMsgChannelEncryptResponse()
+	payload := packet.ReadMsg(body).Payload
+	if uint32(len(payload)) < body.KeySize+4 {
+		return ErrHandshake
+	}
+	encryptedKey := payload[:body.KeySize]
+	if binary.LittleEndian.Uint32(payload[body.KeySize:]) != crc32.ChecksumIEEE(encryptedKey) {
+		return ErrHandshake
+	}
+	sessionKey, err := rsa.DecryptOAEP(sha1.New(), nil, c.server.key, encryptedKey, nil)
+	if err != nil || len(sessionKey) != 32 {
+		return ErrHandshake
+	}
+
+	result := NewMsgChannelEncryptResult()
+	result.Result = EResult_OK
+	err = c.send(NewMsg(result, nil))
+	if err != nil {
+		return err
+	}
+	c.conn.SetEncryptionKey(sessionKey)
+	return nil
+}
+
+// Sends the fixtures triggered by a message of the given type.
+func (c *conn) runScript(eMsg EMsg) {
+	for len(c.script) > 0 && c.script[0].After == eMsg {
+		for _, msg := range c.script[0].Messages {
+			c.send(msg)
+		}
+		c.script = c.script[1:]
+	}
+}
+
+func (c *conn) handlePacket(packet *PacketMsg) {
+	for _, eMsg := range c.server.Ignore {
+		if packet.EMsg == eMsg {
+			return
+		}
+	}
+	switch packet.EMsg {
+	case EMsg_ClientLogon, EMsg_ClientLogonGameServer:
+		c.handleLogOn(packet)
+	case EMsg_ClientLogOff:
+		c.send(NewClientMsgProtobuf(EMsg_ClientLoggedOff, &CMsgClientLoggedOff{
+			Eresult: proto.Int32(int32(EResult_OK)),
+		}))
+	case EMsg_ClientGetAppOwnershipTicket:
+		c.handleAppOwnershipTicket(packet)
+	case EMsg_ClientRequestEncryptedAppTicket:
+		c.handleEncryptedAppTicket(packet)
+	case EMsg_ClientAuthList:
+		c.handleAuthList(packet)
+	}
+}
+
+// Answers with the ticket AppOwnershipTicket returns.
+func (c *conn) handleAppOwnershipTicket(packet *PacketMsg) {
+	body := new(CMsgClientGetAppOwnershipTicket)
+	packet.ReadProtoMsg(body)
+	response := NewClientMsgProtobuf(EMsg_ClientGetAppOwnershipTicketResponse, &CMsgClientGetAppOwnershipTicketResponse{
+		Eresult: proto.Uint32(uint32(EResult_OK)),
+		AppId:   proto.Uint32(body.GetAppId()),
+		Ticket:  AppOwnershipTicket(body.GetAppId()),
+	})
+	response.SetTargetJobId(packet.SourceJobId)
+	c.send(response)
+}
+
+// Answers with the ticket NewEncryptedAppTicket returns.
+func (c *conn) handleEncryptedAppTicket(packet *PacketMsg) {
+	body := new(CMsgClientRequestEncryptedAppTicket)
+	packet.ReadProtoMsg(body)
+	response := NewClientMsgProtobuf(EMsg_ClientRequestEncryptedAppTicketResponse, &CMsgClientRequestEncryptedAppTicketResponse{
+		AppId:              proto.Uint32(body.GetAppId()),
+		Eresult:            proto.Int32(int32(EResult_OK)),
+		EncryptedAppTicket: NewEncryptedAppTicket(body.GetAppId(), c.server.accountSteamId(), body.GetUserdata()),
+	})
+	response.SetTargetJobId(packet.SourceJobId)
+	c.send(response)
+}
+
+// Acknowledges all tickets of the list.
+func (c *conn) handleAuthList(packet *PacketMsg) {
+	body := new(CMsgClientAuthList)
+	packet.ReadProtoMsg(body)
+	ack := &CMsgClientAuthListAck{
+		AppIds:          body.GetAppIds(),
+		MessageSequence: proto.Uint32(body.GetMessageSequence()),
+	}
+	for _, ticket := range body.GetTickets() {
+		ack.TicketCrc = append(ack.TicketCrc, ticket.GetTicketCrc())
+	}
+	c.send(NewClientMsgProtobuf(EMsg_ClientAuthListAck, ack))
+}
+
+func (c *conn) handleLogOn(packet *PacketMsg) {
+	if !packet.IsProto {
+		return
+	}
+	steamId := SteamId(packet.ReadProtoMsg(new(CMsgClientLogon)).Header.Proto.GetSteamid())
+	if steamId.GetAccountType() == int32(EAccountType_Individual) {
+		steamId = c.server.accountSteamId()
+	}
+	result := c.server.logOnResult()
+	if result == 0 {
+		result = EResult_OK
+	}
+
+	response := NewClientMsgProtobuf(EMsg_ClientLogOnResponse, &CMsgClientLogonResponse{
+		Eresult:                   proto.Int32(int32(result)),
+		OutOfGameHeartbeatSeconds: proto.Int32(9),
+		InGameHeartbeatSeconds:    proto.Int32(9),
+		Rtime32ServerTime:         proto.Uint32(uint32(time.Now().Unix())),
+	})
+	if result == EResult_OK {
+		response.Header.Proto.ClientSessionid = proto.Int32(c.sessionId)
+		response.Header.Proto.Steamid = proto.Uint64(uint64(steamId))
+	}
+	c.send(response)
+}
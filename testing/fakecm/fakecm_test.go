@@ -0,0 +1,235 @@
+package fakecm
+
+import (
+	"bytes"
+	"context"
+	"github.com/gamingrobot/steamgo"
+	"github.com/gamingrobot/steamgo/connection"
+	. "github.com/gamingrobot/steamgo/internal"
+	"github.com/gamingrobot/steamgo/servers"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"testing"
+	"time"
+)
+
+var friendId = NewIdAdv(1, 1, int32(EUniverse_Public), EAccountType_Individual)
+
+// A client connected to the server, with its events.
+type testClient struct {
+	*steamgo.Client
+	t      *testing.T
+	events chan interface{}
+}
+
+func newTestClient(t *testing.T) *testClient {
+	client := &testClient{Client: steamgo.NewClient(), t: t, events: make(chan interface{}, 100)}
+	client.Servers, _ = servers.NewServerList(nil)
+	client.Subscribe(func(event interface{}) {
+		client.events <- event
+	})
+	return client
+}
+
+func connect(t *testing.T, server *Server) *testClient {
+	return connectClient(t, newTestClient(t), server)
+}
+
+func connectClient(t *testing.T, client *testClient, server *Server) *testClient {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.ConnectContext(ctx, server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Waits for the first event accepted by fn, skipping the others.
+func (c *testClient) waitFor(name string, fn func(event interface{}) bool) interface{} {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-c.events:
+			if fn(event) {
+				return event
+			}
+		case <-timeout:
+			c.t.Fatalf("Timed out waiting for %v", name)
+		}
+	}
+}
+
+func (c *testClient) waitForChat(message string) {
+	c.waitFor("chat message "+message, func(event interface{}) bool {
+		e, ok := event.(steamgo.ChatMsgEvent)
+		return ok && e.ChatterId == friendId && e.Message == message
+	})
+}
+
+func start(t *testing.T, server *Server) {
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogOnWithFixtures(t *testing.T) {
+	server := NewServer()
+	server.Script = []Fixture{{
+		After: EMsg_ClientLogon,
+		Messages: []IMsg{
+			FriendsList(false, Friend{friendId, EFriendRelationship_Friend}),
+			PersonaState(Persona{SteamId: friendId, Name: "Alice", State: EPersonaState_Online}),
+			CMList("10.0.0.1:27017"),
+		},
+	}, {
+		After:    EMsg_ClientRequestFriendData,
+		Messages: []IMsg{Multi(true, ChatMessage(friendId, "hello"), ChatMessage(friendId, "there"))},
+	}}
+	start(t, server)
+	defer server.Close()
+
+	client := connect(t, server)
+	defer client.Close(context.Background())
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+
+	client.waitFor("LoggedOnEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.LoggedOnEvent)
+		return ok
+	})
+	if client.SteamId() != DefaultSteamId {
+		t.Fatalf("Expected SteamId %v, got %v", DefaultSteamId, client.SteamId())
+	}
+	client.waitFor("FriendsListEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.FriendsListEvent)
+		return ok
+	})
+	if _, err := client.Social.Friends.ById(friendId); err != nil {
+		t.Fatal(err)
+	}
+	client.waitFor("PersonaStateEvent", func(event interface{}) bool {
+		e, ok := event.(steamgo.PersonaStateEvent)
+		return ok && e.FriendId == friendId && e.Name == "Alice" && e.State == EPersonaState_Online
+	})
+	client.waitForChat("hello")
+	client.waitForChat("there")
+
+	found := false
+	for _, s := range client.Servers.GetCopy() {
+		found = found || s.Address == "10.0.0.1:27017"
+	}
+	if !found {
+		t.Fatal("Expected the CM list to be merged into the client's servers")
+	}
+
+	server.Send(ChatMessage(friendId, "anytime"))
+	client.waitForChat("anytime")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	packet, err := server.WaitFor(ctx, EMsg_ClientLogon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logon := new(CMsgClientLogon)
+	packet.ReadProtoMsg(logon)
+	if logon.GetAccountName() != "user" || logon.GetPassword() != "password" {
+		t.Fatalf("Wrong logon %v", logon)
+	}
+}
+
+func TestLogOnFailure(t *testing.T) {
+	server := NewServer()
+	server.LogOnResult = EResult_InvalidPassword
+	start(t, server)
+	defer server.Close()
+
+	client := connect(t, server)
+	defer client.Close(context.Background())
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "wrong"})
+	client.waitFor("DisconnectedEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.DisconnectedEvent)
+		return ok
+	})
+	if client.Connected() {
+		t.Fatal("Expected the client to disconnect")
+	}
+}
+
+func TestLogOff(t *testing.T) {
+	server := NewServer()
+	start(t, server)
+	defer server.Close()
+
+	client := connect(t, server)
+	client.Auth.LogOnAnonymous()
+	client.waitFor("LoggedOnEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.LoggedOnEvent)
+		return ok
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Auth.LogOff(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client.Connected() {
+		t.Fatal("Expected the client to disconnect after logging off")
+	}
+	if err := client.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := NewServer()
+	server.Script = []Fixture{{
+		After:    EMsg_ClientLogon,
+		Messages: []IMsg{FriendsList(false, Friend{friendId, EFriendRelationship_Friend})},
+	}, {
+		After:    EMsg_ClientRequestFriendData,
+		Messages: []IMsg{Multi(false, ChatMessage(friendId, "hello"))},
+	}}
+	start(t, server)
+	defer server.Close()
+
+	capture := new(bytes.Buffer)
+	recorder, err := connection.NewCaptureWriter(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t)
+	client.Recorder = recorder
+	connectClient(t, client, server)
+	client.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	client.waitForChat("hello")
+	if err := client.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server isn't needed anymore
+	server.Close()
+	conn, err := connection.NewReplayConnection(bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := replay.ConnectWith(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close(context.Background())
+	replay.Auth.LogOn(steamgo.LogOnDetails{Username: "user", Password: "password"})
+	replay.waitFor("LoggedOnEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.LoggedOnEvent)
+		return ok
+	})
+	if replay.SteamId() != DefaultSteamId {
+		t.Fatalf("Expected SteamId %v, got %v", DefaultSteamId, replay.SteamId())
+	}
+	replay.waitForChat("hello")
+	// the capture ends like a closed connection
+	replay.waitFor("DisconnectedEvent", func(event interface{}) bool {
+		_, ok := event.(steamgo.DisconnectedEvent)
+		return ok
+	})
+}
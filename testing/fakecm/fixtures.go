@@ -0,0 +1,170 @@
+package fakecm
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"compress/gzip"
+	"crypto/aes"
+	"encoding/binary"
+	"github.com/gamingrobot/steamgo/cryptoutil"
+	. "github.com/gamingrobot/steamgo/internal"
+	. "github.com/gamingrobot/steamgo/steamid"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"time"
+)
+
+type Friend struct {
+	SteamId      SteamId
+	Relationship EFriendRelationship
+}
+
+// Creates a friends list. Incremental lists only contain changes.
+func FriendsList(incremental bool, friends ...Friend) IMsg {
+	list := &CMsgClientFriendsList{Bincremental: proto.Bool(incremental)}
+	for _, friend := range friends {
+		list.Friends = append(list.Friends, &CMsgClientFriendsList_Friend{
+			Ulfriendid:          proto.Uint64(uint64(friend.SteamId)),
+			Efriendrelationship: proto.Uint32(uint32(friend.Relationship)),
+		})
+	}
+	return NewClientMsgProtobuf(EMsg_ClientFriendsList, list)
+}
+
+type Persona struct {
+	SteamId   SteamId
+	Name      string
+	State     EPersonaState
+	GameAppId uint32
+	GameName  string
+}
+
+// Creates a persona state update with the names, presence and games of the personas.
+func PersonaState(personas ...Persona) IMsg {
+	flags := EClientPersonaStateFlag_PlayerName | EClientPersonaStateFlag_Presence | EClientPersonaStateFlag_GameDataBlob
+	state := &CMsgClientPersonaState{StatusFlags: proto.Uint32(uint32(flags))}
+	for _, persona := range personas {
+		state.Friends = append(state.Friends, &CMsgClientPersonaState_Friend{
+			Friendid:        proto.Uint64(uint64(persona.SteamId)),
+			PlayerName:      proto.String(persona.Name),
+			PersonaState:    proto.Uint32(uint32(persona.State)),
+			GamePlayedAppId: proto.Uint32(persona.GameAppId),
+			GameName:        proto.String(persona.GameName),
+		})
+	}
+	return NewClientMsgProtobuf(EMsg_ClientPersonaState, state)
+}
+
+// Creates a chat message from a friend.
+func ChatMessage(from SteamId, message string) IMsg {
+	return NewClientMsgProtobuf(EMsg_ClientFriendMsgIncoming, &CMsgClientFriendMsgIncoming{
+		SteamidFrom:   proto.Uint64(uint64(from)),
+		ChatEntryType: proto.Int32(int32(EChatEntryType_ChatMsg)),
+		Message:       append([]byte(message), 0),
+	})
+}
+
+// Creates a message with game connect tokens, which clients need for session tickets.
+func GameConnectTokens(tokens ...[]byte) IMsg {
+	return NewClientMsgProtobuf(EMsg_ClientGameConnectTokens, &CMsgClientGameConnectTokens{
+		MaxTokensToKeep: proto.Uint32(10),
+		Tokens:          tokens,
+	})
+}
+
+// Returns the app ownership ticket the server hands out for the app.
+func AppOwnershipTicket(appId uint32) []byte {
+	return []byte("ownership ticket for " + strconv.FormatUint(uint64(appId), 10))
+}
+
+// The key the server encrypts app tickets with, to be passed to
+// cryptoutil.DecryptEncryptedAppTicket.
+var AppTicketKey = []byte("fakecm encrypted app ticket key!")
+
+// Creates the encrypted app ticket the server hands out for the app, with an
+// ownership ticket without licenses or DLC.
+func NewEncryptedAppTicket(appId uint32, steamId SteamId, userData []byte) *EncryptedAppTicket {
+	ownership := new(bytes.Buffer)
+	now := uint32(time.Now().Unix())
+	for _, v := range []interface{}{
+		uint32(0), // length, set below
+		uint32(4), // version
+		uint64(steamId),
+		appId,
+		uint32(0x7f000001), // external IP
+		uint32(0x7f000001), // internal IP
+		uint32(0),          // flags
+		now,
+		now + 21*24*60*60, // expiry
+		uint16(0),         // licenses
+		uint16(0),         // DLC
+		uint16(0),         // reserved
+	} {
+		binary.Write(ownership, binary.LittleEndian, v)
+	}
+	ownershipBytes := ownership.Bytes()
+	binary.LittleEndian.PutUint32(ownershipBytes, uint32(len(ownershipBytes)))
+
+	plain := append(append([]byte(nil), userData...), ownershipBytes...)
+	ciph, err := aes.NewCipher(AppTicketKey)
+	if err != nil {
+		panic(err)
+	}
+	return &EncryptedAppTicket{
+		TicketVersionNo:     proto.Uint32(1),
+		CrcEncryptedticket:  proto.Uint32(crc32.ChecksumIEEE(plain)),
+		CbEncrypteduserdata: proto.Uint32(uint32(len(userData))),
+		EncryptedTicket:     cryptoutil.SymmetricEncrypt(ciph, plain),
+	}
+}
+
+// Creates a list of CMs. Panics if an address isn't an IPv4 address with a port.
+func CMList(addresses ...string) IMsg {
+	list := new(CMsgClientCMList)
+	for _, address := range addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			panic(err)
+		}
+		ip := net.ParseIP(host).To4()
+		if ip == nil {
+			panic("Not an IPv4 address: " + host)
+		}
+		portNumber, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			panic(err)
+		}
+		list.CmAddresses = append(list.CmAddresses, binary.BigEndian.Uint32(ip))
+		list.CmPorts = append(list.CmPorts, uint32(portNumber))
+	}
+	return NewClientMsgProtobuf(EMsg_ClientCMList, list)
+}
+
+// Bundles the messages in one Multi message, compressed with gzip if gzipped is
+// set. Panics if a message can't be serialized.
+func Multi(gzipped bool, msgs ...IMsg) IMsg {
+	payload := new(bytes.Buffer)
+	for _, msg := range msgs {
+		buf := new(bytes.Buffer)
+		err := msg.Serialize(buf)
+		if err != nil {
+			panic(err)
+		}
+		binary.Write(payload, binary.LittleEndian, uint32(buf.Len()))
+		payload.Write(buf.Bytes())
+	}
+
+	multi := new(CMsgMulti)
+	if gzipped {
+		compressed := new(bytes.Buffer)
+		writer := gzip.NewWriter(compressed)
+		writer.Write(payload.Bytes())
+		writer.Close()
+		multi.SizeUnzipped = proto.Uint32(uint32(payload.Len()))
+		multi.MessageBody = compressed.Bytes()
+	} else {
+		multi.MessageBody = payload.Bytes()
+	}
+	return NewClientMsgProtobuf(EMsg_Multi, multi)
+}
@@ -19,31 +19,32 @@ type Trading struct {
 
 type TradeRequestId uint32
 
-func (t *Trading) HandlePacket(packet *PacketMsg) {
-	switch packet.EMsg {
-	case EMsg_EconTrading_InitiateTradeProposed:
-		msg := new(CMsgTrading_InitiateTradeRequest)
-		packet.ReadProtoMsg(msg)
-		t.client.Emit(TradeProposedEvent{
-			RequestId: TradeRequestId(msg.GetTradeRequestId()),
-			Other:     SteamId(msg.GetOtherSteamid()),
-			OtherName: msg.GetOtherName(),
-		})
-	case EMsg_EconTrading_InitiateTradeResult:
-		msg := new(CMsgTrading_InitiateTradeResponse)
-		packet.ReadProtoMsg(msg)
-		t.client.Emit(TradeResultEvent{
-			RequestId: TradeRequestId(msg.GetTradeRequestId()),
-			Response:  EEconTradeResponse(msg.GetResponse()),
-			Other:     SteamId(msg.GetOtherSteamid()),
-		})
-	case EMsg_EconTrading_StartSession:
-		msg := new(CMsgTrading_StartSession)
-		packet.ReadProtoMsg(msg)
-		t.client.Emit(TradeSessionStartEvent{
-			Other: SteamId(msg.GetOtherSteamid()),
-		})
-	}
+func (t *Trading) registerHandlers(r *Router) {
+	r.HandleBody(EMsg_EconTrading_InitiateTradeProposed, t.handleTradeProposed)
+	r.HandleBody(EMsg_EconTrading_InitiateTradeResult, t.handleTradeResult)
+	r.HandleBody(EMsg_EconTrading_StartSession, t.handleStartSession)
+}
+
+func (t *Trading) handleTradeProposed(packet *PacketMsg, msg *CMsgTrading_InitiateTradeRequest) {
+	t.client.Emit(TradeProposedEvent{
+		RequestId: TradeRequestId(msg.GetTradeRequestId()),
+		Other:     SteamId(msg.GetOtherSteamid()),
+		OtherName: msg.GetOtherName(),
+	})
+}
+
+func (t *Trading) handleTradeResult(packet *PacketMsg, msg *CMsgTrading_InitiateTradeResponse) {
+	t.client.Emit(TradeResultEvent{
+		RequestId: TradeRequestId(msg.GetTradeRequestId()),
+		Response:  EEconTradeResponse(msg.GetResponse()),
+		Other:     SteamId(msg.GetOtherSteamid()),
+	})
+}
+
+func (t *Trading) handleStartSession(packet *PacketMsg, msg *CMsgTrading_StartSession) {
+	t.client.Emit(TradeSessionStartEvent{
+		Other: SteamId(msg.GetOtherSteamid()),
+	})
 }
 
 // Requests a trade. You'll receive a TradeResultEvent if the request fails or
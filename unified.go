@@ -0,0 +1,177 @@
+package steamgo
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"fmt"
+	. "github.com/gamingrobot/steamgo/internal"
+	"reflect"
+	"sync"
+)
+
+// Provides access to the unified service methods, e.g. Player or PublishedFile,
+// whose messages are named like "CPlayer_GetGameBadgeLevels_Request".
+type Unified struct {
+	client *Client
+
+	mutex    sync.RWMutex
+	handlers map[string]func([]byte)
+}
+
+func newUnified(client *Client) *Unified {
+	return &Unified{
+		client:   client,
+		handlers: make(map[string]func([]byte)),
+	}
+}
+
+// Returned by Call if the service answered with an EResult other than OK.
+type ServiceMethodError struct {
+	Method  string
+	Result  EResult
+	Message string
+}
+
+func (e *ServiceMethodError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%v failed with %v: %v", e.Method, e.Result, e.Message)
+	}
+	return fmt.Sprintf("%v failed with %v", e.Method, e.Result)
+}
+
+// Calls a service method like "Player.GetGameBadgeLevels#1" and decodes the
+// response into resp, which may be nil if it isn't needed. Errors reported by
+// the service are returned as *ServiceMethodError.
+func (u *Unified) Call(ctx context.Context, method string, req, resp proto.Message) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	packet, err := u.client.Request(ctx, NewClientMsgProtobuf(EMsg_ClientServiceMethod, &CMsgClientServiceMethod{
+		MethodName:       proto.String(method),
+		SerializedMethod: body,
+	}))
+	if err != nil {
+		return err
+	}
+
+	response := new(CMsgClientServiceMethodResponse)
+	msg := packet.ReadProtoMsg(response)
+	if result := EResult(msg.Header.Proto.GetEresult()); result != EResult_OK {
+		return &ServiceMethodError{
+			Method:  method,
+			Result:  result,
+			Message: msg.Header.Proto.GetErrorMessage(),
+		}
+	}
+	if resp == nil {
+		return nil
+	}
+	return proto.Unmarshal(response.GetSerializedMethodResponse(), resp)
+}
+
+// Calls a service method like Call before logging on. Steam only accepts this for
+// a few methods, e.g. those of the Authentication service.
+func (u *Unified) CallNonAuthed(ctx context.Context, method string, req, resp proto.Message) error {
+	msg := NewClientMsgProtobuf(EMsg_ServiceMethodCallFromClientNonAuthed, req)
+	msg.Header.Proto.TargetJobName = proto.String(method)
+	packet, err := u.client.Request(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	header := NewMsgHdrProtoBuf()
+	buf := bytes.NewBuffer(packet.Data)
+	header.Deserialize(buf)
+	if result := EResult(header.Proto.GetEresult()); result != EResult_OK {
+		return &ServiceMethodError{
+			Method:  method,
+			Result:  result,
+			Message: header.Proto.GetErrorMessage(),
+		}
+	}
+	if resp == nil {
+		return nil
+	}
+	return proto.Unmarshal(buf.Bytes(), resp)
+}
+
+// Sends a notification to a service method, which isn't answered.
+func (u *Unified) Notify(method string, req proto.Message) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	u.client.Write(NewClientMsgProtobuf(EMsg_ClientServiceMethod, &CMsgClientServiceMethod{
+		MethodName:       proto.String(method),
+		SerializedMethod: body,
+		IsNotification:   proto.Bool(true),
+	}))
+	return nil
+}
+
+// Registers a handler for the notifications Steam sends for the given method,
+// e.g. "PlayerClient.NotifyLastPlayedTimes#1". The handler must be a function like
+//
+//	func(body *CPlayer_LastPlayedTimes_Notification)
+//
+// It replaces any handler registered before for the same method and panics if the
+// handler has a different signature. Notifications without a handler are emitted
+// as ServiceMethodNotificationEvent.
+func (u *Unified) HandleNotification(method string, handler interface{}) {
+	fn := reflect.ValueOf(handler)
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 || !t.In(0).Implements(protoType) ||
+		t.In(0).Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("steamgo: invalid notification handler %v", t))
+	}
+	bodyType := t.In(0).Elem()
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.handlers[method] = func(data []byte) {
+		body := reflect.New(bodyType)
+		err := proto.Unmarshal(data, body.Interface().(proto.Message))
+		if err != nil {
+			u.client.Errorf("Error decoding notification %v: %v", method, err)
+			return
+		}
+		fn.Call([]reflect.Value{body})
+	}
+}
+
+// Removes the handler for the given method.
+func (u *Unified) RemoveNotificationHandler(method string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	delete(u.handlers, method)
+}
+
+func (u *Unified) registerHandlers(r *Router) {
+	r.Handle(EMsg_ServiceMethod, u.handleServiceMethod)
+}
+
+// Incoming notifications carry the method name in the header.
+func (u *Unified) handleServiceMethod(packet *PacketMsg) {
+	header := NewMsgHdrProtoBuf()
+	buf := bytes.NewBuffer(packet.Data)
+	err := header.Deserialize(buf)
+	if err != nil {
+		u.client.Errorf("Error reading service method header: %v", err)
+		return
+	}
+	method := header.Proto.GetTargetJobName()
+
+	u.mutex.RLock()
+	handler := u.handlers[method]
+	u.mutex.RUnlock()
+	if handler != nil {
+		handler(buf.Bytes())
+		return
+	}
+	u.client.Emit(ServiceMethodNotificationEvent{
+		Method: method,
+		Body:   buf.Bytes(),
+	})
+}
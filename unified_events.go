@@ -0,0 +1,10 @@
+package steamgo
+
+import ()
+
+// Emitted for notifications from a service method without a handler registered
+// with Unified.HandleNotification. The body is the serialized protobuf message.
+type ServiceMethodNotificationEvent struct {
+	Method string
+	Body   []byte
+}
@@ -0,0 +1,211 @@
+package steamgo
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"context"
+	"errors"
+	. "github.com/gamingrobot/steamgo/internal"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A connection that parses everything the client writes. Packets from Steam are
+// passed to the client's handlePacket by the test instead.
+type fakeConnection struct {
+	written   chan *PacketMsg
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (f *fakeConnection) Read() (*PacketMsg, error) {
+	<-f.closed
+	return nil, errors.New("Connection closed")
+}
+
+func (f *fakeConnection) Write(data []byte) error {
+	packet, err := NewPacketMsg(append([]byte(nil), data...))
+	if err != nil {
+		return err
+	}
+	f.written <- packet
+	return nil
+}
+
+func (f *fakeConnection) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+func (f *fakeConnection) SetEncryptionKey([]byte) {}
+
+func (f *fakeConnection) IsEncrypted() bool {
+	return true
+}
+
+// Waits for the next message the client wrote and checks its EMsg.
+func (f *fakeConnection) expect(t *testing.T, eMsg EMsg) *PacketMsg {
+	select {
+	case packet := <-f.written:
+		if packet.EMsg != eMsg {
+			t.Fatalf("Expected %v, got %v", eMsg, packet.EMsg)
+		}
+		return packet
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %v", eMsg)
+		return nil
+	}
+}
+
+// Makes the client write to a fakeConnection as if it was connected to a CM.
+func connectFake(client *Client) *fakeConnection {
+	conn := &fakeConnection{
+		written: make(chan *PacketMsg, 100),
+		closed:  make(chan struct{}),
+	}
+	s := newSession(client, conn, "fake", time.Now())
+	s.setSecure()
+	client.mutex.Lock()
+	old := client.detach()
+	client.session = s
+	client.address = "fake"
+	client.goroutines.Add(2)
+	client.mutex.Unlock()
+	if old != nil {
+		old.conn.Close()
+	}
+	go s.writeLoop()
+	go s.heartbeatLoop()
+	return conn
+}
+
+const badgesMethod = "Player.GetGameBadgeLevels#1"
+
+// Answers the service method call in packet with the response and result.
+func answerServiceMethod(t *testing.T, client *Client, packet *PacketMsg, response proto.Message, result EResult, message string) {
+	body := new(CMsgClientServiceMethod)
+	packet.ReadProtoMsg(body)
+	if body.GetMethodName() != badgesMethod || body.GetIsNotification() {
+		t.Fatalf("Unexpected call %v", body)
+	}
+	var serialized []byte
+	if response != nil {
+		serialized, _ = proto.Marshal(response)
+	}
+	msg := NewClientMsgProtobuf(EMsg_ClientServiceMethodResponse, &CMsgClientServiceMethodResponse{
+		MethodName:               proto.String(badgesMethod),
+		SerializedMethodResponse: serialized,
+	})
+	msg.Header.Proto.Eresult = proto.Int32(int32(result))
+	if message != "" {
+		msg.Header.Proto.ErrorMessage = proto.String(message)
+	}
+	msg.SetTargetJobId(packet.SourceJobId)
+	client.handlePacket(packetOf(t, msg))
+}
+
+func TestServiceMethodCall(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp := new(CPlayer_GetGameBadgeLevels_Response)
+	result := make(chan error, 1)
+	go func() {
+		result <- client.Unified.Call(ctx, badgesMethod, &CPlayer_GetGameBadgeLevels_Request{Appid: proto.Uint32(440)}, resp)
+	}()
+	packet := conn.expect(t, EMsg_ClientServiceMethod)
+	body := new(CMsgClientServiceMethod)
+	packet.ReadProtoMsg(body)
+	req := new(CPlayer_GetGameBadgeLevels_Request)
+	if err := proto.Unmarshal(body.GetSerializedMethod(), req); err != nil || req.GetAppid() != 440 {
+		t.Fatalf("Unexpected request %v, %v", req, err)
+	}
+	answerServiceMethod(t, client, packet, &CPlayer_GetGameBadgeLevels_Response{PlayerLevel: proto.Uint32(42)}, EResult_OK, "")
+	if err := <-result; err != nil || resp.GetPlayerLevel() != 42 {
+		t.Fatalf("Unexpected response %v, %v", resp, err)
+	}
+
+	go func() {
+		result <- client.Unified.Call(ctx, badgesMethod, new(CPlayer_GetGameBadgeLevels_Request), nil)
+	}()
+	answerServiceMethod(t, client, conn.expect(t, EMsg_ClientServiceMethod), nil, EResult_InvalidParam, "No app")
+	err := <-result
+	if e, ok := err.(*ServiceMethodError); !ok || e.Method != badgesMethod || e.Result != EResult_InvalidParam || e.Message != "No app" {
+		t.Fatalf("Expected a ServiceMethodError, got %v", err)
+	}
+}
+
+func TestServiceMethodNotify(t *testing.T) {
+	client := NewClient()
+	conn := connectFake(client)
+	defer client.Disconnect()
+
+	err := client.Unified.Notify(badgesMethod, &CPlayer_GetGameBadgeLevels_Request{Appid: proto.Uint32(440)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := conn.expect(t, EMsg_ClientServiceMethod)
+	body := new(CMsgClientServiceMethod)
+	packet.ReadProtoMsg(body)
+	if body.GetMethodName() != badgesMethod || !body.GetIsNotification() {
+		t.Fatalf("Unexpected notification %v", body)
+	}
+	if packet.SourceJobId != InvalidJobId {
+		t.Fatalf("Expected no job for a notification, got %v", packet.SourceJobId)
+	}
+}
+
+func TestServiceMethodNotifications(t *testing.T) {
+	client := NewClient()
+	const method = "PlayerClient.NotifyLastPlayedTimes#1"
+	notification := NewClientMsgProtobuf(EMsg_ServiceMethod, &CPlayer_GetLastPlayedTimes_Response{
+		Games: []*CPlayer_GetLastPlayedTimes_Response_Game{{Appid: proto.Int32(440)}},
+	})
+	notification.Header.Proto.TargetJobName = proto.String(method)
+
+	var handled []int32
+	client.Unified.HandleNotification(method, func(body *CPlayer_GetLastPlayedTimes_Response) {
+		handled = append(handled, body.GetGames()[0].GetAppid())
+	})
+	client.handlePacket(packetOf(t, notification))
+	if len(handled) != 1 || handled[0] != 440 {
+		t.Fatalf("Expected the decoded notification, got %v", handled)
+	}
+
+	client.Unified.RemoveNotificationHandler(method)
+	client.handlePacket(packetOf(t, notification))
+	if len(handled) != 1 {
+		t.Fatal("Expected the handler to be removed")
+	}
+	select {
+	case event := <-client.Events():
+		e, ok := event.(ServiceMethodNotificationEvent)
+		body := new(CPlayer_GetLastPlayedTimes_Response)
+		if !ok || e.Method != method || proto.Unmarshal(e.Body, body) != nil || body.GetGames()[0].GetAppid() != 440 {
+			t.Fatalf("Unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the ServiceMethodNotificationEvent")
+	}
+}
+
+func TestInvalidNotificationHandler(t *testing.T) {
+	for _, handler := range []interface{}{
+		func() {},
+		func(CPlayer_GetLastPlayedTimes_Response) {},
+		func(*CPlayer_GetLastPlayedTimes_Response) error { return nil },
+		"not a function",
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %T to be rejected", handler)
+				}
+			}()
+			NewClient().Unified.HandleNotification(badgesMethod, handler)
+		}()
+	}
+}
@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/gamingrobot/steamgo/cryptoutil"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrWrongPassphrase = errors.New("Wrong passphrase or corrupted vault file")
+	ErrInvalidFile     = errors.New("Not a vault file")
+)
+
+const (
+	fileMagic      = "SGV1"
+	saltSize       = 16
+	keyIterations  = 100000
+	keySize        = 32
+	fileExtension  = ".vault"
+	filePermission = 0600
+)
+
+// Keeps the credentials of each account in an encrypted file in a directory.
+// The files are encrypted with AES-GCM using a key derived from a passphrase
+// and a random salt, which is stored in the file.
+type FileStore struct {
+	dir        string
+	passphrase []byte
+
+	mutex    sync.Mutex
+	keys     map[string][]byte // derived keys by salt, since deriving is slow on purpose
+	saveSalt []byte            // used for all files saved by this store
+}
+
+func NewFileStore(dir, passphrase string) *FileStore {
+	return &FileStore{
+		dir:        dir,
+		passphrase: []byte(passphrase),
+		keys:       make(map[string][]byte),
+	}
+}
+
+func (f *FileStore) path(account string) string {
+	return filepath.Join(f.dir, filepath.Base(account)+fileExtension)
+}
+
+func (f *FileStore) key(salt []byte) []byte {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	key, ok := f.keys[string(salt)]
+	if !ok {
+		key = cryptoutil.DeriveKey(f.passphrase, salt, keyIterations, keySize)
+		f.keys[string(salt)] = key
+	}
+	return key
+}
+
+// Returns the salt for saving, generating it on first use.
+func (f *FileStore) salt() ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.saveSalt == nil {
+		salt := make([]byte, saltSize)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return nil, err
+		}
+		f.saveSalt = salt
+	}
+	return f.saveSalt, nil
+}
+
+func (f *FileStore) Load(account string) (*Credentials, error) {
+	data, err := ioutil.ReadFile(f.path(account))
+	if os.IsNotExist(err) {
+		return new(Credentials), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) < len(fileMagic)+saltSize || !bytes.HasPrefix(data, []byte(fileMagic)) {
+		return nil, ErrInvalidFile
+	}
+	salt := data[len(fileMagic) : len(fileMagic)+saltSize]
+	plain, err := cryptoutil.OpenGCM(f.key(salt), data[len(fileMagic)+saltSize:])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	credentials := new(Credentials)
+	return credentials, json.Unmarshal(plain, credentials)
+}
+
+// Encrypts the credentials and replaces the account's file atomically.
+func (f *FileStore) Save(account string, credentials *Credentials) error {
+	plain, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	salt, err := f.salt()
+	if err != nil {
+		return err
+	}
+	sealed, err := cryptoutil.SealGCM(f.key(salt), plain)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.dir, 0700)
+	if err != nil {
+		return err
+	}
+	data := append(append([]byte(fileMagic), salt...), sealed...)
+	// a unique name, so that concurrent saves don't write the same temporary file
+	tmp, err := os.CreateTemp(f.dir, filepath.Base(f.path(account))+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = tmp.Chmod(filePermission)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(account))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+// Stores the credentials of Steam accounts, so that bots can log on again
+// without passwords or Steam Guard codes.
+package vault
+
+import (
+	"sync"
+)
+
+// Everything needed to log on again and use the website.
+type Credentials struct {
+	// The sentry file Steam uses to recognize this machine
+	Sentry []byte
+	// The key of the last LoginKeyEvent
+	LoginKey string
+	// The refresh token of the last Authentication session
+	RefreshToken string
+	// Lets Authentication sessions skip email codes
+	GuardData string
+	// The `sessionid` cookie of the Steam website
+	WebSessionId string
+	// The `steamLogin` cookie of the Steam website
+	SteamLogin string
+}
+
+// Persists credentials by account name. Implementations must be safe for
+// concurrent use.
+type AccountStore interface {
+	// Returns the credentials of the account, or empty credentials if there are none yet.
+	Load(account string) (*Credentials, error)
+	Save(account string, credentials *Credentials) error
+}
+
+// Serializes Update calls, so that concurrent updates of different credentials
+// of the same account don't overwrite each other.
+var updateMutex sync.Mutex
+
+// Loads the credentials of the account, changes them with fn and saves them.
+func Update(store AccountStore, account string, fn func(*Credentials)) error {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	credentials, err := store.Load(account)
+	if err != nil {
+		return err
+	}
+	fn(credentials)
+	return store.Save(account, credentials)
+}
+
+// Keeps credentials in memory, e.g. for tests or if they are persisted elsewhere.
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	accounts map[string]Credentials
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{accounts: make(map[string]Credentials)}
+}
+
+func (m *MemoryStore) Load(account string) (*Credentials, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	credentials := m.accounts[account]
+	credentials.Sentry = append([]byte(nil), credentials.Sentry...)
+	return &credentials, nil
+}
+
+func (m *MemoryStore) Save(account string, credentials *Credentials) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	copied := *credentials
+	copied.Sentry = append([]byte(nil), credentials.Sentry...)
+	m.accounts[account] = copied
+	return nil
+}
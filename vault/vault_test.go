@@ -0,0 +1,106 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFileStore(dir, "correct horse")
+	credentials, err := store.Load("account")
+	if err != nil || credentials.LoginKey != "" {
+		t.Fatalf("Expected empty credentials for a new account, got %+v (%v)", credentials, err)
+	}
+	err = Update(store, "account", func(c *Credentials) {
+		c.Sentry = []byte{1, 2, 3}
+		c.LoginKey = "login key"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Update(store, "account", func(c *Credentials) {
+		c.RefreshToken = "refresh token"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "account.vault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "login key") {
+		t.Fatal("Credentials are stored in plain text")
+	}
+
+	credentials, err = NewFileStore(dir, "correct horse").Load("account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credentials.Sentry) != 3 || credentials.LoginKey != "login key" || credentials.RefreshToken != "refresh token" {
+		t.Fatalf("Wrong credentials %+v", credentials)
+	}
+
+	_, err = NewFileStore(dir, "wrong").Load("account")
+	if err != ErrWrongPassphrase {
+		t.Fatalf("Expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestFileStoreConcurrentSaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFileStore(dir, "correct horse")
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Save("account", &Credentials{LoginKey: "login key"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "account.vault" || files[0].Mode().Perm() != 0600 {
+		t.Fatalf("Expected only the vault file to be left, got %v", files)
+	}
+	if credentials, err := store.Load("account"); err != nil || credentials.LoginKey != "login key" {
+		t.Fatalf("Wrong credentials %+v (%v)", credentials, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	sentry := []byte{1, 2, 3}
+	store.Save("account", &Credentials{Sentry: sentry})
+	sentry[0] = 0
+	credentials, _ := store.Load("account")
+	if credentials.Sentry[0] != 1 {
+		t.Fatal("The store must copy the sentry")
+	}
+}
@@ -6,36 +6,43 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"github.com/gamingrobot/steamgo/connection"
 	"github.com/gamingrobot/steamgo/cryptoutil"
 	. "github.com/gamingrobot/steamgo/internal"
 	"github.com/gamingrobot/steamgo/keys"
+	"github.com/gamingrobot/steamgo/vault"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Web struct {
 	// The `sessionid` cookie required to use the steam website.
 	WebSessionId string
 	// The `steamLogin` cookie required to use the steam website.
-	// It is only available after calling LogOn().
+	// It is only available after calling LogOn(), or after logging on if the
+	// Auth's AccountStore kept it from an earlier session.
 	SteamLogin string
 
 	webLoginKey  string
 	relogOnNonce uint32
 
+	transportMutex sync.Mutex // guarding dialer and transport
+	dialer         connection.Dialer
+	transport      *http.Transport // connecting with dialer
+
 	client *Client
 }
 
-func (w *Web) HandlePacket(packet *PacketMsg) {
-	switch packet.EMsg {
-	case EMsg_ClientNewLoginKey:
-		w.handleNewLoginKey(packet)
-	case EMsg_ClientRequestWebAPIAuthenticateUserNonceResponse:
-		w.handleAuthNonceResponse(packet)
-	}
+func (w *Web) registerHandlers(r *Router) {
+	r.Handle(EMsg_ClientNewLoginKey, w.handleNewLoginKey)
+	r.Handle(EMsg_ClientRequestWebAPIAuthenticateUserNonceResponse, w.handleAuthNonceResponse)
+	r.HandleBody(EMsg_ClientLogOnResponse, w.handleLogOnResponse)
 }
 
 // Fetches the `steamLogin` cookie. This may only be called after the first
@@ -74,7 +81,7 @@ func (w *Web) apiLogOn() error {
 	data.Add("steamid", strconv.FormatUint(uint64(w.client.SteamId()), 10))
 	data.Add("sessionkey", string(cryptedSessionKey))
 	data.Add("encrypted_loginkey", string(cryptedLoginKey))
-	resp, err := http.PostForm("http://api.steampowered.com/ISteamUserAuth/AuthenticateUser/v0001", data)
+	resp, err := w.httpClient().PostForm("http://api.steampowered.com/ISteamUserAuth/AuthenticateUser/v0001", data)
 	if err != nil {
 		return err
 	}
@@ -102,11 +109,47 @@ func (w *Web) apiLogOn() error {
 	}
 
 	w.SteamLogin = result.Authenticateuser.Token
+	w.client.Auth.updateCredentials(w.client.Auth.accountName(), func(c *vault.Credentials) {
+		c.WebSessionId = w.WebSessionId
+		c.SteamLogin = w.SteamLogin
+	})
 
 	w.client.Emit(WebLoggedOnEvent{})
 	return nil
 }
 
+// Returns an HTTP client that connects using the client's Dialer. Its transport is
+// reused until the Dialer changes, so that idle connections are kept alive.
+func (w *Web) httpClient() *http.Client {
+	dialer := w.client.Dialer
+	if dialer == nil {
+		return http.DefaultClient
+	}
+
+	w.transportMutex.Lock()
+	defer w.transportMutex.Unlock()
+	if w.transport == nil || !sameDialer(w.dialer, dialer) {
+		if w.transport != nil {
+			w.transport.CloseIdleConnections()
+		}
+		w.dialer = dialer
+		w.transport = &http.Transport{
+			DialContext:         connection.ContextDialFunc(dialer),
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+		}
+	}
+	return &http.Client{Transport: w.transport}
+}
+
+// Reports if both dialers are the same. Comparing them with == would panic for
+// dynamic types that aren't comparable, like functions or structs containing slices.
+// Those are never considered the same, so their transport isn't reused.
+func sameDialer(a, b connection.Dialer) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
 func (w *Web) handleNewLoginKey(packet *PacketMsg) {
 	msg := new(CMsgClientNewLoginKey)
 	packet.ReadProtoMsg(msg)
@@ -122,6 +165,24 @@ func (w *Web) handleNewLoginKey(packet *PacketMsg) {
 	w.client.Emit(WebSessionIdEvent{})
 }
 
+// Restores the cookies saved in the AccountStore, so that they can be used until
+// LogOn fetched new ones.
+func (w *Web) handleLogOnResponse(packet *PacketMsg, body *CMsgClientLogonResponse) {
+	store, username := w.client.Auth.AccountStore, w.client.Auth.accountName()
+	if EResult(body.GetEresult()) != EResult_OK || store == nil || username == "" || w.SteamLogin != "" {
+		return
+	}
+	credentials, err := store.Load(username)
+	if err != nil {
+		w.client.Errorf("web: Error loading the cookies of %v: %v", username, err)
+		return
+	}
+	if credentials.SteamLogin != "" {
+		w.WebSessionId = credentials.WebSessionId
+		w.SteamLogin = credentials.SteamLogin
+	}
+}
+
 func (w *Web) handleAuthNonceResponse(packet *PacketMsg) {
 	// this has to be the best name for a message yet.
 	msg := new(CMsgClientRequestWebAPIAuthenticateUserNonceResponse)
@@ -0,0 +1,81 @@
+package steamgo
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// Counts the connections it opens.
+type countingDialer struct {
+	net.Dialer
+	dials int32
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	atomic.AddInt32(&d.dials, 1)
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
+func get(t *testing.T, client *http.Client, url string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+}
+
+func TestWebReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewClient()
+	dialer := new(countingDialer)
+	client.Dialer = dialer
+	for i := 0; i < 3; i++ {
+		get(t, client.Web.httpClient(), server.URL)
+	}
+	if dials := atomic.LoadInt32(&dialer.dials); dials != 1 {
+		t.Fatalf("Expected one connection for all requests, got %v", dials)
+	}
+
+	other := new(countingDialer)
+	client.Dialer = other
+	get(t, client.Web.httpClient(), server.URL)
+	if dials := atomic.LoadInt32(&other.dials); dials != 1 {
+		t.Fatalf("Expected the new dialer to be used, got %v connections", dials)
+	}
+	if dials := atomic.LoadInt32(&dialer.dials); dials != 1 {
+		t.Fatalf("Expected the old dialer to be unused, got %v connections", dials)
+	}
+}
+
+// A dialer whose dynamic type isn't comparable.
+type dialFunc func(network, address string) (net.Conn, error)
+
+func (f dialFunc) Dial(network, address string) (net.Conn, error) {
+	return f(network, address)
+}
+
+func TestWebWithIncomparableDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewClient()
+	var dials int32
+	client.Dialer = dialFunc(func(network, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return net.Dial(network, address)
+	})
+	for i := 0; i < 2; i++ {
+		get(t, client.Web.httpClient(), server.URL)
+	}
+	if dials := atomic.LoadInt32(&dials); dials != 2 {
+		t.Fatalf("Expected a connection per transport, got %v", dials)
+	}
+}